@@ -27,6 +27,12 @@ func InitApi(appContext context.Context, errCh chan struct{}, doneCh chan struct
 		handlers.NewAuthHandler(servicesContainer),
 		handlers.NewNotebooksHandler(servicesContainer),
 		handlers.NewNotesHandler(servicesContainer),
+		handlers.NewTemplatesHandler(servicesContainer),
+		handlers.NewJobsHandler(servicesContainer),
+		handlers.NewCollabHandler(servicesContainer),
+		handlers.NewSearchHandler(servicesContainer),
+		handlers.NewTagsHandler(servicesContainer),
+		handlers.NewLinksHandler(servicesContainer),
 	}
 
 	for _, h := range handlers {
@@ -71,3 +77,21 @@ func InitApi(appContext context.Context, errCh chan struct{}, doneCh chan struct
 	close(muxDoneCh)
 	doneCh <- struct{}{}
 }
+
+// Reindex rebuilds the search index, links and note sizes from the stored notes. The server should not run meanwhile,
+// updates stored during the rebuild may be indexed with outdated content.
+func Reindex(c config.Config) error {
+	repoContainer := db.NewRepositoryContainer(c)
+	defer func() {
+		repoDoneCh := make(chan struct{})
+		go repoContainer.Shutdown(repoDoneCh)
+		<-repoDoneCh
+	}()
+	servicesContainer := services.NewServicesContainer(c, repoContainer)
+	indexed, err := servicesContainer.SearchService().Reindex()
+	if err != nil {
+		return err
+	}
+	log.Printf("Indexed %d notes\n", indexed)
+	return nil
+}
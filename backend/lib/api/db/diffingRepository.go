@@ -1,29 +1,210 @@
 package db
 
 import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
 type DiffingRepository interface {
-	AddDiff(noteId uuid.UUID, diffId uuid.UUID) error
+	AddDiff(noteId uuid.UUID, diffId uuid.UUID, authorId uuid.UUID, stats models.VersionStats) error
+	GetVersion(noteId uuid.UUID, versionId uuid.UUID) (models.NoteVersion, error)
+	// GetVersionsBetween returns the versions after afterVersion up to and including untilVersion, ordered from newest to oldest
+	GetVersionsBetween(noteId uuid.UUID, afterVersion int, untilVersion int) ([]models.NoteVersion, error)
+	// GetVersions returns at most limit versions older than beforeVersion, ordered from newest to oldest
+	GetVersions(noteId uuid.UUID, beforeVersion int, limit int) ([]models.NoteVersion, error)
+	AddSnapshot(noteId uuid.UUID, snapshot models.NoteSnapshot) error
+	// GetNearestSnapshot returns the oldest snapshot of a version not older than version
+	GetNearestSnapshot(noteId uuid.UUID, version int) (models.NoteSnapshot, error)
+	// GetLatestSnapshot returns the snapshot of the most recent version that has one
+	GetLatestSnapshot(noteId uuid.UUID) (models.NoteSnapshot, error)
+	// UpdateCurrentSize sets the size of a note and of its most recent version without changing when the note was updated
+	UpdateCurrentSize(noteId uuid.UUID, versionId uuid.UUID, size int64, lineCount int) error
 }
 
 type diffingRepositoryImpl struct {
 	db *sqlx.DB
 }
 
+type noteVersionEntity struct {
+	Id           int            `db:"rowid"`
+	UUID         string         `db:"id"`
+	Version      int            `db:"version"`
+	CreatedAt    time.Time      `db:"created_at"`
+	AuthorId     sql.NullString `db:"author_id"`
+	Author       sql.NullString `db:"author"`
+	Size         int64          `db:"size"`
+	LineCount    int            `db:"line_count"`
+	LinesAdded   int            `db:"lines_added"`
+	LinesRemoved int            `db:"lines_removed"`
+	DiffSize     int64          `db:"diff_size"`
+}
+
+const selectVersions = `SELECT note_diffs.rowid, note_diffs.id, note_diffs.version, note_diffs.created_at, note_diffs.author_id, users.username AS author,
+	note_diffs.size, note_diffs.line_count, note_diffs.lines_added, note_diffs.lines_removed, note_diffs.diff_size
+	FROM note_diffs
+	LEFT JOIN users on users.id = note_diffs.author_id`
+
 // AddDiff implements DiffingRepository.
-func (d diffingRepositoryImpl) AddDiff(noteId uuid.UUID, diffId uuid.UUID) error {
+func (d diffingRepositoryImpl) AddDiff(noteId uuid.UUID, diffId uuid.UUID, authorId uuid.UUID, stats models.VersionStats) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(
+		`INSERT INTO note_diffs(id, note_id, version, author_id, size, line_count, lines_added, lines_removed, diff_size)
+		VALUES($1, $2, (SELECT COALESCE(MAX(version), 0) + 1 FROM note_diffs WHERE note_id = $2), $3, $4, $5, $6, $7, $8)`,
+		diffId, noteId, authorId, stats.Size, stats.LineCount, stats.LinesAdded, stats.LinesRemoved, stats.DiffSize); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE notes SET size = $1, updated_at = strftime('%s','now') WHERE id = $2`, stats.Size, noteId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateCurrentSize implements DiffingRepository.
+func (d diffingRepositoryImpl) UpdateCurrentSize(noteId uuid.UUID, versionId uuid.UUID, size int64, lineCount int) error {
 	tx, err := d.db.Begin()
 	if err != nil {
 		return err
 	}
-	defer tx.Commit()
-	if _, err := tx.Exec(`INSERT INTO note_diffs(id, note_id) VALUES($1, $2)`, diffId, noteId); err != nil {
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE notes SET size = $1 WHERE id = $2", size, noteId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE note_diffs SET size = $1, line_count = $2 WHERE id = $3 and note_id = $4",
+		size, lineCount, versionId, noteId); err != nil {
 		return err
 	}
-	return nil
+	return tx.Commit()
+}
+
+// GetVersion implements DiffingRepository.
+func (d diffingRepositoryImpl) GetVersion(noteId uuid.UUID, versionId uuid.UUID) (models.NoteVersion, error) {
+	var entity noteVersionEntity
+	if err := d.db.Get(&entity, selectVersions+" WHERE note_diffs.id = $1 and note_diffs.note_id = $2", versionId.String(), noteId.String()); err != nil {
+		return models.NoteVersion{}, err
+	}
+	return versionEntityToModel(entity)
+}
+
+// GetVersionsBetween implements DiffingRepository.
+func (d diffingRepositoryImpl) GetVersionsBetween(noteId uuid.UUID, afterVersion int, untilVersion int) ([]models.NoteVersion, error) {
+	var entities []noteVersionEntity
+	if err := d.db.Select(&entities, selectVersions+
+		` WHERE note_diffs.note_id = $1 and note_diffs.version > $2 and note_diffs.version <= $3
+		ORDER BY note_diffs.version DESC`, noteId.String(), afterVersion, untilVersion); err != nil {
+		return nil, err
+	}
+	return versionEntitiesToModels(entities), nil
+}
+
+// GetVersions implements DiffingRepository.
+func (d diffingRepositoryImpl) GetVersions(noteId uuid.UUID, beforeVersion int, limit int) ([]models.NoteVersion, error) {
+	var entities []noteVersionEntity
+	if err := d.db.Select(&entities, selectVersions+
+		` WHERE note_diffs.note_id = $1 and note_diffs.version < $2
+		ORDER BY note_diffs.version DESC
+		LIMIT $3`, noteId.String(), beforeVersion, limit); err != nil {
+		return nil, err
+	}
+	return versionEntitiesToModels(entities), nil
+}
+
+type snapshotEntity struct {
+	VersionId string    `db:"version_id"`
+	Version   int       `db:"version"`
+	Size      int64     `db:"size"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// AddSnapshot implements DiffingRepository.
+func (d diffingRepositoryImpl) AddSnapshot(noteId uuid.UUID, snapshot models.NoteSnapshot) error {
+	_, err := d.db.Exec("INSERT INTO note_snapshots(version_id, note_id, version, size) VALUES($1, $2, $3, $4)",
+		snapshot.VersionId.String(), noteId.String(), snapshot.Version, snapshot.Size)
+	return err
+}
+
+// GetNearestSnapshot implements DiffingRepository.
+func (d diffingRepositoryImpl) GetNearestSnapshot(noteId uuid.UUID, version int) (models.NoteSnapshot, error) {
+	var entity snapshotEntity
+	if err := d.db.Get(&entity, `SELECT version_id, version, size, created_at FROM note_snapshots
+		WHERE note_id = $1 and version >= $2
+		ORDER BY version LIMIT 1`, noteId.String(), version); err != nil {
+		return models.NoteSnapshot{}, err
+	}
+	return snapshotEntityToModel(entity)
+}
+
+// GetLatestSnapshot implements DiffingRepository.
+func (d diffingRepositoryImpl) GetLatestSnapshot(noteId uuid.UUID) (models.NoteSnapshot, error) {
+	var entity snapshotEntity
+	if err := d.db.Get(&entity, `SELECT version_id, version, size, created_at FROM note_snapshots
+		WHERE note_id = $1
+		ORDER BY version DESC LIMIT 1`, noteId.String()); err != nil {
+		return models.NoteSnapshot{}, err
+	}
+	return snapshotEntityToModel(entity)
+}
+
+func snapshotEntityToModel(e snapshotEntity) (models.NoteSnapshot, error) {
+	versionId, err := uuid.Parse(e.VersionId)
+	if err != nil {
+		return models.NoteSnapshot{}, err
+	}
+	return models.NoteSnapshot{
+		VersionId: versionId,
+		Version:   e.Version,
+		Size:      e.Size,
+		CreatedAt: e.CreatedAt,
+	}, nil
+}
+
+func versionEntitiesToModels(entities []noteVersionEntity) []models.NoteVersion {
+	versions := make([]models.NoteVersion, 0, len(entities))
+	for _, e := range entities {
+		v, err := versionEntityToModel(e)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+func versionEntityToModel(e noteVersionEntity) (models.NoteVersion, error) {
+	id, err := uuid.Parse(e.UUID)
+	if err != nil {
+		return models.NoteVersion{}, err
+	}
+	version := models.NoteVersion{
+		Id:        id,
+		Version:   e.Version,
+		CreatedAt: e.CreatedAt,
+		Author:    e.Author.String,
+		VersionStats: models.VersionStats{
+			Size:         e.Size,
+			LineCount:    e.LineCount,
+			LinesAdded:   e.LinesAdded,
+			LinesRemoved: e.LinesRemoved,
+			DiffSize:     e.DiffSize,
+		},
+	}
+	if e.AuthorId.Valid {
+		authorId, err := uuid.Parse(e.AuthorId.String)
+		if err != nil {
+			return models.NoteVersion{}, err
+		}
+		version.AuthorId = &authorId
+	}
+	return version, nil
 }
 
 func NewDiffingRepository(db *sqlx.DB) DiffingRepository {
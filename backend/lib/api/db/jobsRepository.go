@@ -0,0 +1,183 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type JobsRepository interface {
+	AddJob(job models.DiffJob) error
+	GetJob(jobId uuid.UUID) (models.DiffJob, error)
+	// GetUnfinishedJobs returns all jobs that are still to be processed in the order they were queued
+	GetUnfinishedJobs() ([]models.DiffJob, error)
+	// MarkRunning starts a new attempt of a job
+	MarkRunning(jobId uuid.UUID) error
+	MarkDone(jobId uuid.UUID, versionId uuid.UUID) error
+	MarkFailed(jobId uuid.UUID, jobErr string, nextAttemptAt time.Time) error
+	MarkDead(jobId uuid.UUID, jobErr string) error
+	MarkRejected(jobId uuid.UUID, reason string) error
+	// DeleteJobOfDeletedNote deletes a job if its note no longer exists and reports whether it did
+	DeleteJobOfDeletedNote(jobId uuid.UUID) (bool, error)
+}
+
+type jobsRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+type jobEntity struct {
+	Id            int            `db:"rowid"`
+	UUID          string         `db:"id"`
+	NoteId        string         `db:"note_id"`
+	AuthorId      sql.NullString `db:"author_id"`
+	State         string         `db:"state"`
+	Attempts      int            `db:"attempts"`
+	Error         string         `db:"error"`
+	VersionId     sql.NullString `db:"version_id"`
+	BaseVersionId sql.NullString `db:"base_version_id"`
+	Merge         bool           `db:"merge"`
+	NextAttemptAt time.Time      `db:"next_attempt_at"`
+	CreatedAt     time.Time      `db:"created_at"`
+	UpdatedAt     time.Time      `db:"updated_at"`
+}
+
+const selectJobs = `SELECT rowid, id, note_id, author_id, state, attempts, error, version_id, base_version_id, merge, next_attempt_at, created_at, updated_at FROM diff_jobs`
+
+// AddJob implements JobsRepository.
+func (j jobsRepositoryImpl) AddJob(job models.DiffJob) error {
+	var baseVersionId sql.NullString
+	if job.BaseVersionId != nil {
+		baseVersionId = sql.NullString{String: job.BaseVersionId.String(), Valid: true}
+	}
+	_, err := j.db.Exec("INSERT INTO diff_jobs(id, note_id, author_id, state, base_version_id, merge) VALUES($1, $2, $3, $4, $5, $6)",
+		job.Id.String(), job.NoteId.String(), job.AuthorId.String(), models.JobQueued, baseVersionId, job.Merge)
+	return err
+}
+
+// GetJob implements JobsRepository.
+func (j jobsRepositoryImpl) GetJob(jobId uuid.UUID) (models.DiffJob, error) {
+	var entity jobEntity
+	if err := j.db.Get(&entity, selectJobs+" WHERE id = $1", jobId.String()); err != nil {
+		return models.DiffJob{}, err
+	}
+	return jobEntityToModel(entity)
+}
+
+// GetUnfinishedJobs implements JobsRepository.
+func (j jobsRepositoryImpl) GetUnfinishedJobs() ([]models.DiffJob, error) {
+	var entities []jobEntity
+	if err := j.db.Select(&entities, selectJobs+" WHERE state NOT IN ($1, $2, $3) ORDER BY rowid", models.JobDone, models.JobDead, models.JobRejected); err != nil {
+		return nil, err
+	}
+	jobs := make([]models.DiffJob, 0, len(entities))
+	for _, e := range entities {
+		job, err := jobEntityToModel(e)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// MarkRunning implements JobsRepository.
+func (j jobsRepositoryImpl) MarkRunning(jobId uuid.UUID) error {
+	_, err := j.db.Exec("UPDATE diff_jobs SET state = $1, attempts = attempts + 1, updated_at = strftime('%s','now') WHERE id = $2",
+		models.JobRunning, jobId.String())
+	return err
+}
+
+// MarkDone implements JobsRepository.
+func (j jobsRepositoryImpl) MarkDone(jobId uuid.UUID, versionId uuid.UUID) error {
+	_, err := j.db.Exec("UPDATE diff_jobs SET state = $1, version_id = $2, error = '', updated_at = strftime('%s','now') WHERE id = $3",
+		models.JobDone, versionId.String(), jobId.String())
+	return err
+}
+
+// MarkFailed implements JobsRepository.
+func (j jobsRepositoryImpl) MarkFailed(jobId uuid.UUID, jobErr string, nextAttemptAt time.Time) error {
+	_, err := j.db.Exec("UPDATE diff_jobs SET state = $1, error = $2, next_attempt_at = $3, updated_at = strftime('%s','now') WHERE id = $4",
+		models.JobFailed, jobErr, nextAttemptAt.Unix(), jobId.String())
+	return err
+}
+
+// MarkDead implements JobsRepository.
+func (j jobsRepositoryImpl) MarkDead(jobId uuid.UUID, jobErr string) error {
+	_, err := j.db.Exec("UPDATE diff_jobs SET state = $1, error = $2, updated_at = strftime('%s','now') WHERE id = $3",
+		models.JobDead, jobErr, jobId.String())
+	return err
+}
+
+// MarkRejected implements JobsRepository.
+func (j jobsRepositoryImpl) MarkRejected(jobId uuid.UUID, reason string) error {
+	_, err := j.db.Exec("UPDATE diff_jobs SET state = $1, error = $2, updated_at = strftime('%s','now') WHERE id = $3",
+		models.JobRejected, reason, jobId.String())
+	return err
+}
+
+// DeleteJobOfDeletedNote implements JobsRepository.
+func (j jobsRepositoryImpl) DeleteJobOfDeletedNote(jobId uuid.UUID) (bool, error) {
+	result, err := j.db.Exec("DELETE FROM diff_jobs WHERE id = $1 and NOT EXISTS (SELECT 1 FROM notes WHERE notes.id = diff_jobs.note_id)",
+		jobId.String())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func jobEntityToModel(e jobEntity) (models.DiffJob, error) {
+	id, err := uuid.Parse(e.UUID)
+	if err != nil {
+		return models.DiffJob{}, err
+	}
+	noteId, err := uuid.Parse(e.NoteId)
+	if err != nil {
+		return models.DiffJob{}, err
+	}
+	job := models.DiffJob{
+		Id:            id,
+		NoteId:        noteId,
+		State:         models.JobState(e.State),
+		Attempts:      e.Attempts,
+		Error:         e.Error,
+		Merge:         e.Merge,
+		NextAttemptAt: e.NextAttemptAt,
+		CreatedAt:     e.CreatedAt,
+		UpdatedAt:     e.UpdatedAt,
+	}
+	if e.AuthorId.Valid {
+		if job.AuthorId, err = uuid.Parse(e.AuthorId.String); err != nil {
+			return models.DiffJob{}, err
+		}
+	}
+	if e.VersionId.Valid {
+		versionId, err := uuid.Parse(e.VersionId.String)
+		if err != nil {
+			return models.DiffJob{}, err
+		}
+		job.VersionId = &versionId
+	}
+	if e.BaseVersionId.Valid {
+		baseVersionId, err := uuid.Parse(e.BaseVersionId.String)
+		if err != nil {
+			return models.DiffJob{}, err
+		}
+		job.BaseVersionId = &baseVersionId
+	}
+	return job, nil
+}
+
+func NewJobsRepository(db *sqlx.DB) JobsRepository {
+	return jobsRepositoryImpl{
+		db: db,
+	}
+}
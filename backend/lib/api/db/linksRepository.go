@@ -0,0 +1,164 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Links store their target as written and are resolved when queried, so links to notes created, renamed or deleted
+// later resolve accordingly. A target resolves to the note with the target as id, otherwise to the oldest note
+// titled like the target. Only notes of the owner of the linking note are considered.
+type LinksRepository interface {
+	// SetLinks replaces the links of a note, targets differing only in case are stored once
+	SetLinks(noteId uuid.UUID, targets []string) error
+	// GetLinks returns the links of a note in order of appearance
+	GetLinks(ownerId uuid.UUID, noteId uuid.UUID) ([]models.NoteLink, error)
+	// GetBacklinks returns the notes linking to a note
+	GetBacklinks(ownerId uuid.UUID, noteId uuid.UUID) ([]models.Note, error)
+	// RetargetLinks changes links of the given notes from one target to another
+	RetargetLinks(noteIds []uuid.UUID, from string, to string) error
+	// HasOtherNoteTitled checks if a note of the owner other than the given one is titled like title, regardless of case
+	HasOtherNoteTitled(ownerId uuid.UUID, noteId uuid.UUID, title string) (bool, error)
+}
+
+type linksRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+// resolveLinkTarget is the id of the note a link in note_links resolves to, ownerParam refers to the owner of the note
+func resolveLinkTarget(ownerParam string) string {
+	return `COALESCE(
+		(SELECT notes.id FROM notes JOIN notebooks ON notebooks.id = notes.notebook_id
+		WHERE notebooks.creater_id = ` + ownerParam + ` and notes.id = note_links.target),
+		(SELECT notes.id FROM notes JOIN notebooks ON notebooks.id = notes.notebook_id
+		WHERE notebooks.creater_id = ` + ownerParam + ` and notes.title = note_links.target COLLATE NOCASE
+		ORDER BY notes.created_at, notes.rowid LIMIT 1))`
+}
+
+type noteLinkEntity struct {
+	Target     string         `db:"target"`
+	NoteId     sql.NullString `db:"note_id"`
+	NotebookId sql.NullString `db:"notebook_id"`
+	Title      sql.NullString `db:"title"`
+}
+
+// SetLinks implements LinksRepository.
+func (l linksRepositoryImpl) SetLinks(noteId uuid.UUID, targets []string) error {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM note_links WHERE source_note_id = $1", noteId.String()); err != nil {
+		return err
+	}
+	for _, target := range targets {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO note_links(source_note_id, target) VALUES ($1, $2)", noteId.String(), target); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetLinks implements LinksRepository.
+func (l linksRepositoryImpl) GetLinks(ownerId uuid.UUID, noteId uuid.UUID) ([]models.NoteLink, error) {
+	var entities []noteLinkEntity
+	if err := l.db.Select(&entities,
+		`SELECT links.target, notes.id AS note_id, notes.notebook_id, notes.title
+		FROM (
+			SELECT note_links.rowid, note_links.target, `+resolveLinkTarget("$1")+` AS target_note_id
+			FROM note_links
+			WHERE note_links.source_note_id = $2
+		) AS links
+		LEFT JOIN notes ON notes.id = links.target_note_id
+		ORDER BY links.rowid`, ownerId.String(), noteId.String()); err != nil {
+		return nil, err
+	}
+	links := make([]models.NoteLink, 0, len(entities))
+	for _, e := range entities {
+		link, err := noteLinkEntityToModel(e)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// GetBacklinks implements LinksRepository.
+func (l linksRepositoryImpl) GetBacklinks(ownerId uuid.UUID, noteId uuid.UUID) ([]models.Note, error) {
+	var entities []noteEntity
+	if err := l.db.Select(&entities, selectNotes+
+		` WHERE notes.id IN (
+			SELECT note_links.source_note_id FROM note_links
+			WHERE (note_links.target = $1 or note_links.target = (SELECT title FROM notes WHERE id = $1) COLLATE NOCASE)
+			and `+resolveLinkTarget("$2")+` = $1
+		)
+		and notes.notebook_id IN (SELECT id FROM notebooks WHERE creater_id = $2)
+		ORDER BY notes.title COLLATE NOCASE`, noteId.String(), ownerId.String()); err != nil {
+		return nil, err
+	}
+	return noteEntitiesToModels(entities), nil
+}
+
+// RetargetLinks implements LinksRepository.
+func (l linksRepositoryImpl) RetargetLinks(noteIds []uuid.UUID, from string, to string) error {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, noteId := range noteIds {
+		if _, err := tx.Exec("UPDATE OR REPLACE note_links SET target = $1 WHERE source_note_id = $2 and target = $3 COLLATE NOCASE",
+			to, noteId.String(), from); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// HasOtherNoteTitled implements LinksRepository.
+func (l linksRepositoryImpl) HasOtherNoteTitled(ownerId uuid.UUID, noteId uuid.UUID, title string) (bool, error) {
+	var count int
+	if err := l.db.Get(&count,
+		`SELECT COUNT(*)
+		FROM notes
+		JOIN notebooks ON notebooks.id = notes.notebook_id
+		WHERE notebooks.creater_id = $1 and notes.id != $2 and notes.title = $3 COLLATE NOCASE`,
+		ownerId.String(), noteId.String(), title); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func NewLinksRepository(db *sqlx.DB) LinksRepository {
+	return linksRepositoryImpl{
+		db: db,
+	}
+}
+
+func noteLinkEntityToModel(e noteLinkEntity) (models.NoteLink, error) {
+	link := models.NoteLink{
+		Target: e.Target,
+	}
+	if !e.NoteId.Valid {
+		return link, nil
+	}
+	noteId, err := uuid.Parse(e.NoteId.String)
+	if err != nil {
+		return models.NoteLink{}, err
+	}
+	notebookId, err := uuid.Parse(e.NotebookId.String)
+	if err != nil {
+		return models.NoteLink{}, err
+	}
+	link.NoteId = &noteId
+	link.NotebookId = &notebookId
+	link.Title = e.Title.String
+	return link, nil
+}
@@ -1,7 +1,10 @@
 package db
 
 import (
+	"database/sql"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
 	"github.com/google/uuid"
@@ -12,6 +15,9 @@ type NotebooksRepository interface {
 	FetchByUserId(userId uuid.UUID) ([]models.Notebook, error)
 	CreateNotebook(userId uuid.UUID, title string, description string) error
 	HasNotebook(userId uuid.UUID, notebookId uuid.UUID) (bool, error)
+	GetNotebookDetails(userId uuid.UUID, notebookId uuid.UUID) (models.NotebookDetails, error)
+	UpdateNotebookMetadata(userId uuid.UUID, notebookId uuid.UUID, metadata models.NotebookMetadata) (bool, error)
+	ReorderNotebooks(userId uuid.UUID, notebookIds []uuid.UUID) error
 }
 
 type notebooksRepositoryImpl struct {
@@ -31,7 +37,9 @@ func (n notebooksRepositoryImpl) CreateNotebook(userId uuid.UUID, title string,
 		return err
 	}
 	defer tx.Commit()
-	if _, err := tx.Exec("INSERT INTO notebooks(creater_id, id, title, description) VALUES ($1, $2, $3, $4)", userId, uuid.New(), title, description); err != nil {
+	if _, err := tx.Exec(
+		`INSERT INTO notebooks(creater_id, id, title, description, sort_order)
+		VALUES ($1, $2, $3, $4, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM notebooks WHERE creater_id = $1))`, userId, uuid.New(), title, description); err != nil {
 		return err
 	}
 	return nil
@@ -42,12 +50,22 @@ type notebooksEntity struct {
 	UUIDId      string `db:"id"`
 	Title       string `db:"title"`
 	Description string `db:"description"`
+	Pinned      bool   `db:"pinned"`
+	Favourite   bool   `db:"favourite"`
+	SortOrder   int    `db:"sort_order"`
+	Color       string `db:"color"`
+	Icon        string `db:"icon"`
+	Emoji       string `db:"emoji"`
 }
 
 // FetchByUserId implements NotebooksRepository.
 func (n notebooksRepositoryImpl) FetchByUserId(userId uuid.UUID) ([]models.Notebook, error) {
 	var notebooksEntities []notebooksEntity
-	if err := n.db.Select(&notebooksEntities, "SELECT rowid, id, title, description FROM notebooks WHERE creater_id = $1", userId.String()); err != nil {
+	if err := n.db.Select(&notebooksEntities,
+		`SELECT rowid, id, title, description, pinned, favourite, sort_order, color, icon, emoji
+		FROM notebooks
+		WHERE creater_id = $1
+		ORDER BY pinned DESC, sort_order, rowid`, userId.String()); err != nil {
 		return nil, err
 	}
 	notebooks := make([]models.Notebook, 0, len(notebooksEntities))
@@ -71,6 +89,121 @@ func (n notebooksRepositoryImpl) HasNotebook(userId uuid.UUID, notebookId uuid.U
 	return count == 1, nil
 }
 
+type notebookDetailsEntity struct {
+	notebooksEntity
+	CreatedAt    time.Time `db:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at"`
+	NoteCount    int       `db:"note_count"`
+	TotalSize    int64     `db:"total_size"`
+	VersionCount int       `db:"version_count"`
+}
+
+// GetNotebookDetails implements NotebooksRepository.
+func (n notebooksRepositoryImpl) GetNotebookDetails(userId uuid.UUID, notebookId uuid.UUID) (models.NotebookDetails, error) {
+	var detailsEntity notebookDetailsEntity
+	if err := n.db.Get(&detailsEntity,
+		`SELECT notebooks.rowid, notebooks.id, notebooks.title, notebooks.description,
+			notebooks.pinned, notebooks.favourite, notebooks.sort_order, notebooks.color, notebooks.icon, notebooks.emoji,
+			notebooks.created_at, notebooks.updated_at,
+			(SELECT COUNT(*) FROM notes WHERE notes.notebook_id = notebooks.id) AS note_count,
+			(SELECT COALESCE(SUM(notes.size), 0) FROM notes WHERE notes.notebook_id = notebooks.id) AS total_size,
+			(SELECT COUNT(*) FROM note_diffs JOIN notes on notes.id = note_diffs.note_id WHERE notes.notebook_id = notebooks.id) AS version_count
+		FROM notebooks
+		WHERE notebooks.id = $1 and notebooks.creater_id = $2`, notebookId.String(), userId.String()); err != nil {
+		return models.NotebookDetails{}, err
+	}
+	notebook, err := n.entityToModel(detailsEntity.notebooksEntity)
+	if err != nil {
+		return models.NotebookDetails{}, err
+	}
+	details := models.NotebookDetails{
+		Notebook:     notebook,
+		NoteCount:    detailsEntity.NoteCount,
+		TotalSize:    detailsEntity.TotalSize,
+		VersionCount: detailsEntity.VersionCount,
+		CreatedAt:    detailsEntity.CreatedAt,
+		UpdatedAt:    detailsEntity.UpdatedAt,
+	}
+
+	var lastModified noteEntity
+	err = n.db.Get(&lastModified, selectNotes+
+		` WHERE notes.notebook_id = $1
+		ORDER BY notes.updated_at DESC, notes.rowid DESC
+		LIMIT 1`, notebookId.String())
+	if err == sql.ErrNoRows {
+		return details, nil
+	}
+	if err != nil {
+		return models.NotebookDetails{}, err
+	}
+	lastModifiedNote, err := noteEntityToModel(lastModified)
+	if err != nil {
+		return models.NotebookDetails{}, err
+	}
+	details.LastModifiedNote = &lastModifiedNote
+	details.LastModifiedAt = &lastModifiedNote.UpdatedAt
+	return details, nil
+}
+
+// UpdateNotebookMetadata implements NotebooksRepository.
+func (n notebooksRepositoryImpl) UpdateNotebookMetadata(userId uuid.UUID, notebookId uuid.UUID, metadata models.NotebookMetadata) (bool, error) {
+	tx, err := n.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Commit()
+	res, err := tx.Exec(
+		`UPDATE notebooks
+		SET pinned = $1, favourite = $2, color = $3, icon = $4, emoji = $5, updated_at = strftime('%s','now')
+		WHERE id = $6 and creater_id = $7`,
+		metadata.Pinned, metadata.Favourite, metadata.Color, metadata.Icon, metadata.Emoji, notebookId.String(), userId.String())
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// ReorderNotebooks implements NotebooksRepository. Notebooks not contained in notebookIds keep
+// their relative order and are placed after the given ones.
+func (n notebooksRepositoryImpl) ReorderNotebooks(userId uuid.UUID, notebookIds []uuid.UUID) error {
+	tx, err := n.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var currentOrder []string
+	if err := tx.Select(&currentOrder, "SELECT id FROM notebooks WHERE creater_id = $1 ORDER BY sort_order, rowid", userId.String()); err != nil {
+		return err
+	}
+	owned := make(map[string]bool, len(currentOrder))
+	for _, id := range currentOrder {
+		owned[id] = true
+	}
+	newOrder := make([]string, 0, len(currentOrder))
+	for _, id := range notebookIds {
+		if !owned[id.String()] {
+			return fmt.Errorf("%w: %s", sql.ErrNoRows, id)
+		}
+		newOrder = append(newOrder, id.String())
+		delete(owned, id.String())
+	}
+	for _, id := range currentOrder {
+		if owned[id] {
+			newOrder = append(newOrder, id)
+		}
+	}
+	for i, id := range newOrder {
+		if _, err := tx.Exec("UPDATE notebooks SET sort_order = $1 WHERE id = $2", i+1, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func (r notebooksRepositoryImpl) entityToModel(n notebooksEntity) (models.Notebook, error) {
 	notebookId, err := uuid.Parse(n.UUIDId)
 	if err != nil {
@@ -80,5 +213,13 @@ func (r notebooksRepositoryImpl) entityToModel(n notebooksEntity) (models.Notebo
 		Id:          notebookId,
 		Description: n.Description,
 		Title:       n.Title,
+		SortOrder:   n.SortOrder,
+		NotebookMetadata: models.NotebookMetadata{
+			Pinned:    n.Pinned,
+			Favourite: n.Favourite,
+			Color:     n.Color,
+			Icon:      n.Icon,
+			Emoji:     n.Emoji,
+		},
 	}, nil
 }
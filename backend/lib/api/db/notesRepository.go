@@ -1,7 +1,9 @@
 package db
 
 import (
+	"database/sql"
 	"log"
+	"time"
 
 	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
 	"github.com/google/uuid"
@@ -9,9 +11,15 @@ import (
 )
 
 type NotesRepository interface {
-	AddNote(notebookId uuid.UUID, noteId uuid.UUID, title string, path string) error
-	GetNotesForNotebook(notebookId uuid.UUID) ([]models.Note, error)
+	AddNote(notebookId uuid.UUID, noteId uuid.UUID, authorId uuid.UUID, title string, path string, stats models.VersionStats) error
+	// GetNotesForNotebook returns the notes of a notebook tagged with all tags
+	GetNotesForNotebook(notebookId uuid.UUID, tags []string) ([]models.Note, error)
+	// GetNotesForUser returns the notes of all notebooks created by the user tagged with all tags
+	GetNotesForUser(userId uuid.UUID, tags []string) ([]models.Note, error)
 	IsNotePartOfNotebook(userId uuid.UUID, notebookId uuid.UUID, noteId uuid.UUID) (bool, error)
+	DeleteNote(noteId uuid.UUID) error
+	GetNote(noteId uuid.UUID) (models.Note, error)
+	UpdateNoteMetadata(noteId uuid.UUID, notebookId uuid.UUID, title string) error
 }
 
 type notesRepositoryImpl struct {
@@ -19,11 +27,22 @@ type notesRepositoryImpl struct {
 }
 
 type noteEntity struct {
-	Id    int    `db:"rowid"`
-	UUID  string `db:"id"`
-	Title string `db:"title"`
+	Id           int            `db:"rowid"`
+	UUID         string         `db:"id"`
+	NotebookId   string         `db:"notebook_id"`
+	Title        string         `db:"title"`
+	Tags         sql.NullString `db:"tags"`
+	Size         int64          `db:"size"`
+	VersionCount int            `db:"version_count"`
+	CreatedAt    time.Time      `db:"created_at"`
+	UpdatedAt    time.Time      `db:"updated_at"`
 }
 
+const selectNotes = `SELECT notes.rowid, notes.id, notes.notebook_id, notes.title, notes.size, notes.created_at, notes.updated_at,
+	(SELECT COUNT(*) FROM note_diffs WHERE note_diffs.note_id = notes.id) AS version_count,
+	` + selectNoteTags + ` AS tags
+	FROM notes`
+
 // IsNotePartOfNotebook implements NotesRepository.
 func (n notesRepositoryImpl) IsNotePartOfNotebook(userId uuid.UUID, notebookId uuid.UUID, noteId uuid.UUID) (bool, error) {
 	var count int32
@@ -39,34 +58,117 @@ func (n notesRepositoryImpl) IsNotePartOfNotebook(userId uuid.UUID, notebookId u
 }
 
 // GetNotesForNotebook implements NotesRepository.
-func (n notesRepositoryImpl) GetNotesForNotebook(notebookId uuid.UUID) ([]models.Note, error) {
+func (n notesRepositoryImpl) GetNotesForNotebook(notebookId uuid.UUID, tags []string) ([]models.Note, error) {
+	filter, tagArgs := tagsFilter(tags, 2)
+	var noteEntities []noteEntity
+	if err := n.db.Select(&noteEntities, selectNotes+" WHERE notes.notebook_id = $1"+filter,
+		append([]any{notebookId}, tagArgs...)...); err != nil {
+		return nil, err
+	}
+	return noteEntitiesToModels(noteEntities), nil
+}
+
+// GetNotesForUser implements NotesRepository.
+func (n notesRepositoryImpl) GetNotesForUser(userId uuid.UUID, tags []string) ([]models.Note, error) {
+	filter, tagArgs := tagsFilter(tags, 2)
 	var noteEntities []noteEntity
-	if err := n.db.Select(&noteEntities, "SELECT rowid, id, title FROM notes WHERE notebook_id = $1", notebookId); err != nil {
+	if err := n.db.Select(&noteEntities, selectNotes+
+		" WHERE notes.notebook_id IN (SELECT id FROM notebooks WHERE creater_id = $1)"+filter+
+		" ORDER BY notes.updated_at DESC", append([]any{userId.String()}, tagArgs...)...); err != nil {
 		return nil, err
 	}
+	return noteEntitiesToModels(noteEntities), nil
+}
+
+func noteEntitiesToModels(noteEntities []noteEntity) []models.Note {
 	notes := make([]models.Note, 0, len(noteEntities))
 	for _, e := range noteEntities {
-		noteModel, err := n.entityToModel(e)
+		noteModel, err := noteEntityToModel(e)
 		if err != nil {
 			log.Println(err)
 			continue
 		}
 		notes = append(notes, noteModel)
 	}
-	return notes, nil
+	return notes
 }
 
 // AddNote implements NotesRepository.
-func (n notesRepositoryImpl) AddNote(notebookId uuid.UUID, noteId uuid.UUID, title string, path string) error {
+func (n notesRepositoryImpl) AddNote(notebookId uuid.UUID, noteId uuid.UUID, authorId uuid.UUID, title string, path string, stats models.VersionStats) error {
 	tx, err := n.db.Begin()
 	if err != nil {
 		return err
 	}
-	defer tx.Commit()
-	if _, err := tx.Exec("INSERT INTO notes(id, notebook_id, title, path) VALUES ($1, $2, $3, $4)", noteId, notebookId, title, path); err != nil {
+	defer tx.Rollback()
+	if _, err := tx.Exec("INSERT INTO notes(id, notebook_id, title, path, size) VALUES ($1, $2, $3, $4, $5)", noteId, notebookId, title, path, stats.Size); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO note_diffs(id, note_id, version, author_id, size, line_count, lines_added, lines_removed, diff_size)
+		VALUES ($1, $2, 0, $3, $4, $5, $6, $7, $8)`,
+		uuid.New(), noteId, authorId, stats.Size, stats.LineCount, stats.LinesAdded, stats.LinesRemoved, stats.DiffSize); err != nil {
 		return err
 	}
-	return err
+	return tx.Commit()
+}
+
+// DeleteNote implements NotesRepository.
+func (n notesRepositoryImpl) DeleteNote(noteId uuid.UUID) error {
+	tx, err := n.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM diff_jobs WHERE note_id = $1", noteId.String()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM note_snapshots WHERE note_id = $1", noteId.String()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM note_diffs WHERE note_id = $1", noteId.String()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM note_links WHERE source_note_id = $1", noteId.String()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM note_tags WHERE note_id = $1", noteId.String()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM note_search WHERE note_id = $1", noteId.String()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM notes WHERE id = $1", noteId.String()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetNote implements NotesRepository.
+func (n notesRepositoryImpl) GetNote(noteId uuid.UUID) (models.Note, error) {
+	var entity noteEntity
+	if err := n.db.Get(&entity, selectNotes+" WHERE notes.id = $1", noteId.String()); err != nil {
+		return models.Note{}, err
+	}
+	return noteEntityToModel(entity)
+}
+
+// UpdateNoteMetadata implements NotesRepository.
+func (n notesRepositoryImpl) UpdateNoteMetadata(noteId uuid.UUID, notebookId uuid.UUID, title string) error {
+	tx, err := n.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(
+		`UPDATE notes
+		SET notebook_id = $1, title = $2, updated_at = strftime('%s','now')
+		WHERE id = $3`, notebookId.String(), title, noteId.String()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE note_search SET title = $1 WHERE note_id = $2", title, noteId.String()); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func NewNotesRepository(db *sqlx.DB) NotesRepository {
@@ -75,13 +177,23 @@ func NewNotesRepository(db *sqlx.DB) NotesRepository {
 	}
 }
 
-func (n notesRepositoryImpl) entityToModel(e noteEntity) (models.Note, error) {
+func noteEntityToModel(e noteEntity) (models.Note, error) {
 	id, err := uuid.Parse(e.UUID)
 	if err != nil {
 		return models.Note{}, err
 	}
+	notebookId, err := uuid.Parse(e.NotebookId)
+	if err != nil {
+		return models.Note{}, err
+	}
 	return models.Note{
-		Id:    id,
-		Title: e.Title,
+		Id:           id,
+		NotebookId:   notebookId,
+		Title:        e.Title,
+		Tags:         splitNoteTags(e.Tags.String),
+		Size:         e.Size,
+		VersionCount: e.VersionCount,
+		CreatedAt:    e.CreatedAt,
+		UpdatedAt:    e.UpdatedAt,
 	}, err
 }
@@ -13,6 +13,11 @@ type repositoryContainerImpl struct {
 	notebooksRepository NotebooksRepository
 	notesRepository     NotesRepository
 	diffingRepository   DiffingRepository
+	templatesRepository TemplatesRepository
+	jobsRepository      JobsRepository
+	searchRepository    SearchRepository
+	tagsRepository      TagsRepository
+	linksRepository     LinksRepository
 }
 
 // Shutdown implements RepositoryContainer.
@@ -26,6 +31,11 @@ type RepositoryContainer interface {
 	NotebooksRepository() NotebooksRepository
 	NotesRepository() NotesRepository
 	DiffingRespository() DiffingRepository
+	TemplatesRepository() TemplatesRepository
+	JobsRepository() JobsRepository
+	SearchRepository() SearchRepository
+	TagsRepository() TagsRepository
+	LinksRepository() LinksRepository
 	Shutdown(chan struct{})
 }
 
@@ -45,6 +55,26 @@ func (r repositoryContainerImpl) DiffingRespository() DiffingRepository {
 	return r.diffingRepository
 }
 
+func (r repositoryContainerImpl) TemplatesRepository() TemplatesRepository {
+	return r.templatesRepository
+}
+
+func (r repositoryContainerImpl) JobsRepository() JobsRepository {
+	return r.jobsRepository
+}
+
+func (r repositoryContainerImpl) SearchRepository() SearchRepository {
+	return r.searchRepository
+}
+
+func (r repositoryContainerImpl) TagsRepository() TagsRepository {
+	return r.tagsRepository
+}
+
+func (r repositoryContainerImpl) LinksRepository() LinksRepository {
+	return r.linksRepository
+}
+
 func NewRepositoryContainer(c config.Config) RepositoryContainer {
 	db, err := sqlx.Connect(c.Db.Driver, c.Db.Path)
 	if err != nil {
@@ -56,5 +86,10 @@ func NewRepositoryContainer(c config.Config) RepositoryContainer {
 		notebooksRepository: NewNotebooksRepository(db),
 		notesRepository:     NewNotesRepository(db),
 		diffingRepository:   NewDiffingRepository(db),
+		templatesRepository: NewTemplatesRepository(db),
+		jobsRepository:      NewJobsRepository(db),
+		searchRepository:    NewSearchRepository(db),
+		tagsRepository:      NewTagsRepository(db),
+		linksRepository:     NewLinksRepository(db),
 	}
 }
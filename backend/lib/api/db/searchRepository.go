@@ -0,0 +1,222 @@
+package db
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/bongofriend/bongo-notes/backend/lib/search"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SearchRepository interface {
+	// IndexNote replaces the indexed content of a note, the title is taken from the note itself
+	IndexNote(noteId uuid.UUID, content string) error
+	// Search returns the notes of notebooks created by the user matching all terms of a query, best matches first
+	Search(userId uuid.UUID, query search.Query, limit int, offset int) ([]models.SearchResult, error)
+	// GetNoteIds returns the ids of all notes
+	GetNoteIds() ([]uuid.UUID, error)
+	// RemoveDeletedNotes drops index entries of notes that do not exist anymore
+	RemoveDeletedNotes() error
+}
+
+// Matches are marked with control characters by SQLite, so the snippets can be escaped before marking them in HTML
+const (
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+var (
+	// stripMatchMarkers removes the markers from indexed content, so only SQLite places them
+	stripMatchMarkers = strings.NewReplacer(matchStart, "", matchEnd, "")
+	markMatches       = strings.NewReplacer(matchStart, "<mark>", matchEnd, "</mark>")
+)
+
+type searchRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+type searchResultEntity struct {
+	NoteId           string    `db:"note_id"`
+	NotebookId       string    `db:"notebook_id"`
+	NotebookTitle    string    `db:"notebook_title"`
+	Title            string    `db:"title"`
+	HighlightedTitle string    `db:"highlighted_title"`
+	Snippet          string    `db:"snippet"`
+	Score            float64   `db:"score"`
+	UpdatedAt        time.Time `db:"updated_at"`
+}
+
+// IndexNote implements SearchRepository.
+func (s searchRepositoryImpl) IndexNote(noteId uuid.UUID, content string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM note_search WHERE note_id = $1", noteId.String()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO note_search(note_id, title, content)
+		SELECT id, title, $1 FROM notes WHERE id = $2`, stripMatchMarkers.Replace(content), noteId.String()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Search implements SearchRepository. Matches in titles weigh more than matches in the content.
+// Queries without full-text terms order the matching notes by their last update.
+func (s searchRepositoryImpl) Search(userId uuid.UUID, query search.Query, limit int, offset int) ([]models.SearchResult, error) {
+	conditions, args, err := compileSearchQuery(userId, query)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, limit, offset)
+	var entities []searchResultEntity
+	if err := s.db.Select(&entities,
+		`SELECT notes.id AS note_id, notebooks.id AS notebook_id, notebooks.title AS notebook_title, notes.title,
+			highlight(note_search, 1, char(2), char(3)) AS highlighted_title,
+			snippet(note_search, 2, char(2), char(3), '…', 24) AS snippet,
+			-bm25(note_search, 0.0, 10.0, 1.0) AS score,
+			notes.updated_at
+		FROM note_search
+		JOIN notes ON notes.id = note_search.note_id
+		JOIN notebooks ON notebooks.id = notes.notebook_id
+		WHERE `+conditions+fmt.Sprintf(`
+		ORDER BY score DESC, notes.updated_at DESC
+		LIMIT $%d OFFSET $%d`, len(args)-1, len(args)), args...); err != nil {
+		return nil, err
+	}
+	results := make([]models.SearchResult, 0, len(entities))
+	for _, e := range entities {
+		result, err := searchResultEntityToModel(e)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// searchConditions collects the conditions of a query together with their arguments.
+// Arguments are numbered in the order they are added, which has to be the order they appear in.
+type searchConditions struct {
+	conditions []string
+	args       []any
+}
+
+func (c *searchConditions) arg(value any) string {
+	c.args = append(c.args, value)
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+func (c *searchConditions) add(condition string, negated bool) {
+	if negated {
+		condition = "NOT (" + condition + ")"
+	}
+	c.conditions = append(c.conditions, condition)
+}
+
+// compileSearchQuery turns a query into SQL conditions on note_search, notes, notebooks and tags. All positive
+// full-text terms form a single FTS5 query used for ranking, negated ones exclude the notes they match.
+func compileSearchQuery(userId uuid.UUID, query search.Query) (string, []any, error) {
+	var c searchConditions
+	c.add("notebooks.creater_id = "+c.arg(userId.String()), false)
+	var matches []string
+	for _, t := range query.Terms {
+		if t.Field.IsFullText() && !t.Negated {
+			matches = append(matches, ftsTerm(t))
+		}
+	}
+	if len(matches) > 0 {
+		c.add("note_search MATCH "+c.arg(strings.Join(matches, " ")), false)
+	}
+	for _, t := range query.Terms {
+		switch {
+		case t.Field.IsFullText():
+			if t.Negated {
+				c.add("notes.id IN (SELECT note_id FROM note_search WHERE note_search MATCH "+c.arg(ftsTerm(t))+")", true)
+			}
+		case t.Field == search.FieldTag:
+			c.add(noteHasTag(c.arg(t.Value)), t.Negated)
+		case t.Field == search.FieldNotebook:
+			c.add("notebooks.title = "+c.arg(t.Value)+" COLLATE NOCASE", t.Negated)
+		case t.Field.IsDate():
+			column := "notes.updated_at"
+			if t.Field == search.FieldCreated {
+				column = "notes.created_at"
+			}
+			var bounds []string
+			if !t.From.IsZero() {
+				bounds = append(bounds, column+" >= "+c.arg(t.From.Unix()))
+			}
+			if !t.Until.IsZero() {
+				bounds = append(bounds, column+" < "+c.arg(t.Until.Unix()))
+			}
+			c.add(strings.Join(bounds, " AND "), t.Negated)
+		default:
+			return "", nil, fmt.Errorf("search field %q is not supported", t.Field)
+		}
+	}
+	return strings.Join(c.conditions, " AND "), c.args, nil
+}
+
+// ftsTerm quotes a term for FTS5, so characters with a meaning in FTS5 queries are searched for literally
+func ftsTerm(t search.Term) string {
+	term := `"` + strings.ReplaceAll(t.Value, `"`, `""`) + `"`
+	if t.Prefix {
+		term += " *"
+	}
+	if t.Field == search.FieldTitle || t.Field == search.FieldContent {
+		term = string(t.Field) + " : " + term
+	}
+	return term
+}
+
+// GetNoteIds implements SearchRepository.
+func (s searchRepositoryImpl) GetNoteIds() ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if err := s.db.Select(&ids, "SELECT id FROM notes ORDER BY rowid"); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// RemoveDeletedNotes implements SearchRepository.
+func (s searchRepositoryImpl) RemoveDeletedNotes() error {
+	_, err := s.db.Exec("DELETE FROM note_search WHERE note_id NOT IN (SELECT id FROM notes)")
+	return err
+}
+
+func NewSearchRepository(db *sqlx.DB) SearchRepository {
+	return searchRepositoryImpl{
+		db: db,
+	}
+}
+
+func searchResultEntityToModel(e searchResultEntity) (models.SearchResult, error) {
+	noteId, err := uuid.Parse(e.NoteId)
+	if err != nil {
+		return models.SearchResult{}, err
+	}
+	notebookId, err := uuid.Parse(e.NotebookId)
+	if err != nil {
+		return models.SearchResult{}, err
+	}
+	return models.SearchResult{
+		NoteId:           noteId,
+		NotebookId:       notebookId,
+		NotebookTitle:    e.NotebookTitle,
+		Title:            e.Title,
+		HighlightedTitle: markMatches.Replace(html.EscapeString(e.HighlightedTitle)),
+		Snippet:          markMatches.Replace(html.EscapeString(e.Snippet)),
+		Score:            e.Score,
+		UpdatedAt:        e.UpdatedAt,
+	}, nil
+}
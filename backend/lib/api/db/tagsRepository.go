@@ -0,0 +1,255 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Tag names are unique per owner regardless of case
+type TagsRepository interface {
+	// GetTags returns the tags of the owner with the number of notes tagged with them
+	GetTags(ownerId uuid.UUID) ([]models.Tag, error)
+	GetTag(ownerId uuid.UUID, tagId uuid.UUID) (models.Tag, error)
+	GetTagByName(ownerId uuid.UUID, name string) (models.Tag, error)
+	// AddTagsToNote tags a note, tags the owner does not have yet are created
+	AddTagsToNote(ownerId uuid.UUID, noteId uuid.UUID, names []string) error
+	// RemoveTagFromNote reports whether the note was tagged with the tag
+	RemoveTagFromNote(ownerId uuid.UUID, noteId uuid.UUID, name string) (bool, error)
+	GetNoteTags(noteId uuid.UUID) ([]string, error)
+	RenameTag(ownerId uuid.UUID, tagId uuid.UUID, name string) (bool, error)
+	// MergeTag tags all notes tagged with a tag with the target tag instead and deletes the tag
+	MergeTag(ownerId uuid.UUID, tagId uuid.UUID, targetId uuid.UUID) (bool, error)
+	// DeleteTag removes a tag from all notes and deletes it
+	DeleteTag(ownerId uuid.UUID, tagId uuid.UUID) (bool, error)
+}
+
+type tagsRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+type tagEntity struct {
+	UUID      string    `db:"id"`
+	Name      string    `db:"name"`
+	NoteCount int       `db:"note_count"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+const selectTags = `SELECT tags.id, tags.name, tags.created_at,
+	(SELECT COUNT(*) FROM note_tags WHERE note_tags.tag_id = tags.id) AS note_count
+	FROM tags`
+
+// noteTagsSeparator joins the tags of a note in queries, tag names cannot contain control characters
+const noteTagsSeparator = "\x1f"
+
+// selectNoteTags is a subquery of the tags of notes, joined by noteTagsSeparator
+const selectNoteTags = `(SELECT group_concat(tags.name, char(31)) FROM note_tags JOIN tags ON tags.id = note_tags.tag_id
+	WHERE note_tags.note_id = notes.id)`
+
+// noteHasTag is a condition on notes to be tagged with the tag named by the parameter
+func noteHasTag(param string) string {
+	return `notes.id IN (SELECT note_tags.note_id FROM note_tags JOIN tags ON tags.id = note_tags.tag_id
+		WHERE tags.name = ` + param + ` COLLATE NOCASE)`
+}
+
+// tagsFilter returns conditions restricting notes to those tagged with all tags. Arguments are numbered
+// after the firstArg - 1 arguments already used by the query.
+func tagsFilter(tags []string, firstArg int) (string, []any) {
+	var b strings.Builder
+	args := make([]any, 0, len(tags))
+	for _, t := range tags {
+		args = append(args, t)
+		b.WriteString(" AND " + noteHasTag(fmt.Sprintf("$%d", firstArg+len(args)-1)))
+	}
+	return b.String(), args
+}
+
+// splitNoteTags splits the tags selected by selectNoteTags and sorts them by name
+func splitNoteTags(joined string) []string {
+	if len(joined) == 0 {
+		return []string{}
+	}
+	tags := strings.Split(joined, noteTagsSeparator)
+	sort.Slice(tags, func(i, j int) bool {
+		return strings.ToLower(tags[i]) < strings.ToLower(tags[j])
+	})
+	return tags
+}
+
+// GetTags implements TagsRepository.
+func (t tagsRepositoryImpl) GetTags(ownerId uuid.UUID) ([]models.Tag, error) {
+	var entities []tagEntity
+	if err := t.db.Select(&entities, selectTags+" WHERE tags.owner_id = $1 ORDER BY tags.name COLLATE NOCASE", ownerId.String()); err != nil {
+		return nil, err
+	}
+	tags := make([]models.Tag, 0, len(entities))
+	for _, e := range entities {
+		tag, err := tagEntityToModel(e)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// GetTag implements TagsRepository.
+func (t tagsRepositoryImpl) GetTag(ownerId uuid.UUID, tagId uuid.UUID) (models.Tag, error) {
+	var entity tagEntity
+	if err := t.db.Get(&entity, selectTags+" WHERE tags.owner_id = $1 and tags.id = $2", ownerId.String(), tagId.String()); err != nil {
+		return models.Tag{}, err
+	}
+	return tagEntityToModel(entity)
+}
+
+// GetTagByName implements TagsRepository.
+func (t tagsRepositoryImpl) GetTagByName(ownerId uuid.UUID, name string) (models.Tag, error) {
+	var entity tagEntity
+	if err := t.db.Get(&entity, selectTags+" WHERE tags.owner_id = $1 and tags.name = $2 COLLATE NOCASE", ownerId.String(), name); err != nil {
+		return models.Tag{}, err
+	}
+	return tagEntityToModel(entity)
+}
+
+// AddTagsToNote implements TagsRepository.
+func (t tagsRepositoryImpl) AddTagsToNote(ownerId uuid.UUID, noteId uuid.UUID, names []string) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, name := range names {
+		if _, err := tx.Exec("INSERT INTO tags(id, owner_id, name) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+			uuid.New().String(), ownerId.String(), name); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			`INSERT OR IGNORE INTO note_tags(note_id, tag_id)
+			SELECT $1, id FROM tags WHERE owner_id = $2 and name = $3 COLLATE NOCASE`, noteId.String(), ownerId.String(), name); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RemoveTagFromNote implements TagsRepository.
+func (t tagsRepositoryImpl) RemoveTagFromNote(ownerId uuid.UUID, noteId uuid.UUID, name string) (bool, error) {
+	res, err := t.db.Exec(
+		`DELETE FROM note_tags
+		WHERE note_id = $1 and tag_id IN (SELECT id FROM tags WHERE owner_id = $2 and name = $3 COLLATE NOCASE)`,
+		noteId.String(), ownerId.String(), name)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// GetNoteTags implements TagsRepository.
+func (t tagsRepositoryImpl) GetNoteTags(noteId uuid.UUID) ([]string, error) {
+	var tags []string
+	if err := t.db.Select(&tags,
+		`SELECT tags.name FROM note_tags JOIN tags ON tags.id = note_tags.tag_id
+		WHERE note_tags.note_id = $1
+		ORDER BY tags.name COLLATE NOCASE`, noteId.String()); err != nil {
+		return nil, err
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	return tags, nil
+}
+
+// RenameTag implements TagsRepository.
+func (t tagsRepositoryImpl) RenameTag(ownerId uuid.UUID, tagId uuid.UUID, name string) (bool, error) {
+	res, err := t.db.Exec("UPDATE tags SET name = $1 WHERE id = $2 and owner_id = $3", name, tagId.String(), ownerId.String())
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// MergeTag implements TagsRepository.
+func (t tagsRepositoryImpl) MergeTag(ownerId uuid.UUID, tagId uuid.UUID, targetId uuid.UUID) (bool, error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM tags WHERE owner_id = $1 and id IN ($2, $3)",
+		ownerId.String(), tagId.String(), targetId.String()).Scan(&count); err != nil {
+		return false, err
+	}
+	if count != 2 {
+		return false, nil
+	}
+	if _, err := tx.Exec(
+		`INSERT OR IGNORE INTO note_tags(note_id, tag_id)
+		SELECT note_id, $1 FROM note_tags WHERE tag_id = $2`, targetId.String(), tagId.String()); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("DELETE FROM note_tags WHERE tag_id = $1", tagId.String()); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("DELETE FROM tags WHERE id = $1", tagId.String()); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// DeleteTag implements TagsRepository.
+func (t tagsRepositoryImpl) DeleteTag(ownerId uuid.UUID, tagId uuid.UUID) (bool, error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(
+		`DELETE FROM note_tags
+		WHERE tag_id IN (SELECT id FROM tags WHERE id = $1 and owner_id = $2)`, tagId.String(), ownerId.String()); err != nil {
+		return false, err
+	}
+	res, err := tx.Exec("DELETE FROM tags WHERE id = $1 and owner_id = $2", tagId.String(), ownerId.String())
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, tx.Commit()
+}
+
+func NewTagsRepository(db *sqlx.DB) TagsRepository {
+	return tagsRepositoryImpl{
+		db: db,
+	}
+}
+
+func tagEntityToModel(e tagEntity) (models.Tag, error) {
+	id, err := uuid.Parse(e.UUID)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	return models.Tag{
+		Id:        id,
+		Name:      e.Name,
+		NoteCount: e.NoteCount,
+		CreatedAt: e.CreatedAt,
+	}, nil
+}
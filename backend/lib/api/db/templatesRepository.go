@@ -0,0 +1,166 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type TemplatesRepository interface {
+	CreateTemplate(ownerId uuid.UUID, notebookId *uuid.UUID, name string, title string, content string) (uuid.UUID, error)
+	GetTemplate(ownerId uuid.UUID, templateId uuid.UUID) (models.NoteTemplate, error)
+	FetchTemplates(ownerId uuid.UUID, notebookId *uuid.UUID) ([]models.NoteTemplate, error)
+	UpdateTemplate(ownerId uuid.UUID, templateId uuid.UUID, name string, title string, content string) (bool, error)
+	DeleteTemplate(ownerId uuid.UUID, templateId uuid.UUID) (bool, error)
+}
+
+type templatesRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+type templateEntity struct {
+	Id         int            `db:"rowid"`
+	UUID       string         `db:"id"`
+	NotebookId sql.NullString `db:"notebook_id"`
+	Name       string         `db:"name"`
+	Title      string         `db:"title"`
+	Content    string         `db:"content"`
+	CreatedAt  time.Time      `db:"created_at"`
+	UpdatedAt  time.Time      `db:"updated_at"`
+}
+
+func NewTemplatesRepository(db *sqlx.DB) TemplatesRepository {
+	return templatesRepositoryImpl{
+		db: db,
+	}
+}
+
+// CreateTemplate implements TemplatesRepository.
+func (t templatesRepositoryImpl) CreateTemplate(ownerId uuid.UUID, notebookId *uuid.UUID, name string, title string, content string) (uuid.UUID, error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Commit()
+	templateId := uuid.New()
+	var notebook sql.NullString
+	if notebookId != nil {
+		notebook = sql.NullString{String: notebookId.String(), Valid: true}
+	}
+	if _, err := tx.Exec("INSERT INTO note_templates(id, owner_id, notebook_id, name, title, content) VALUES ($1, $2, $3, $4, $5, $6)",
+		templateId.String(), ownerId.String(), notebook, name, title, content); err != nil {
+		return uuid.Nil, err
+	}
+	return templateId, nil
+}
+
+// GetTemplate implements TemplatesRepository.
+func (t templatesRepositoryImpl) GetTemplate(ownerId uuid.UUID, templateId uuid.UUID) (models.NoteTemplate, error) {
+	var entity templateEntity
+	if err := t.db.Get(&entity,
+		`SELECT rowid, id, notebook_id, name, title, content, created_at, updated_at
+		FROM note_templates
+		WHERE id = $1 and owner_id = $2`, templateId.String(), ownerId.String()); err != nil {
+		return models.NoteTemplate{}, err
+	}
+	return t.entityToModel(entity)
+}
+
+// FetchTemplates implements TemplatesRepository.
+func (t templatesRepositoryImpl) FetchTemplates(ownerId uuid.UUID, notebookId *uuid.UUID) ([]models.NoteTemplate, error) {
+	var entities []templateEntity
+	var err error
+	if notebookId == nil {
+		err = t.db.Select(&entities,
+			`SELECT rowid, id, notebook_id, name, title, content, created_at, updated_at
+			FROM note_templates
+			WHERE owner_id = $1
+			ORDER BY name`, ownerId.String())
+	} else {
+		err = t.db.Select(&entities,
+			`SELECT rowid, id, notebook_id, name, title, content, created_at, updated_at
+			FROM note_templates
+			WHERE owner_id = $1 and (notebook_id IS NULL or notebook_id = $2)
+			ORDER BY name`, ownerId.String(), notebookId.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+	templates := make([]models.NoteTemplate, 0, len(entities))
+	for _, e := range entities {
+		template, err := t.entityToModel(e)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+// UpdateTemplate implements TemplatesRepository.
+func (t templatesRepositoryImpl) UpdateTemplate(ownerId uuid.UUID, templateId uuid.UUID, name string, title string, content string) (bool, error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Commit()
+	res, err := tx.Exec(
+		`UPDATE note_templates
+		SET name = $1, title = $2, content = $3, updated_at = strftime('%s','now')
+		WHERE id = $4 and owner_id = $5`, name, title, content, templateId.String(), ownerId.String())
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// DeleteTemplate implements TemplatesRepository.
+func (t templatesRepositoryImpl) DeleteTemplate(ownerId uuid.UUID, templateId uuid.UUID) (bool, error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Commit()
+	res, err := tx.Exec("DELETE FROM note_templates WHERE id = $1 and owner_id = $2", templateId.String(), ownerId.String())
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (t templatesRepositoryImpl) entityToModel(e templateEntity) (models.NoteTemplate, error) {
+	templateId, err := uuid.Parse(e.UUID)
+	if err != nil {
+		return models.NoteTemplate{}, err
+	}
+	var notebookId *uuid.UUID
+	if e.NotebookId.Valid {
+		id, err := uuid.Parse(e.NotebookId.String)
+		if err != nil {
+			return models.NoteTemplate{}, err
+		}
+		notebookId = &id
+	}
+	return models.NoteTemplate{
+		Id:         templateId,
+		NotebookId: notebookId,
+		Name:       e.Name,
+		Title:      e.Title,
+		Content:    e.Content,
+		CreatedAt:  e.CreatedAt,
+		UpdatedAt:  e.UpdatedAt,
+	}, nil
+}
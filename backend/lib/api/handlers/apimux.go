@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
 	"github.com/bongofriend/bongo-notes/backend/lib/api/services"
@@ -109,8 +111,8 @@ func (s serviceErrorBodyResponse[T]) WriteResponse(w http.ResponseWriter) {
 		w.Write([]byte("Internal Sever Error"))
 		return
 	}
-	w.WriteHeader(s.StatusCode)
 	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(s.StatusCode)
 	w.Write(data)
 }
 
@@ -154,12 +156,32 @@ func (s successBodyResponse[T]) WriteResponse(w http.ResponseWriter) {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("Internal Server Error"))
 	} else {
-		w.WriteHeader(s.statusCode)
 		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(s.statusCode)
 		w.Write(data)
 	}
 }
 
+type successContentResponse struct {
+	statusCode  int
+	contentType string
+	content     []byte
+}
+
+func (s successContentResponse) WriteResponse(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", s.contentType)
+	w.WriteHeader(s.statusCode)
+	w.Write(s.content)
+}
+
+func ServiceContentSuccessResponse(statusCode int, contentType string, content []byte) ServiceResponse {
+	return successContentResponse{
+		statusCode:  statusCode,
+		contentType: contentType,
+		content:     content,
+	}
+}
+
 func ServiceSuccessBodyResponse[T any](statusCode int, body T) ServiceResponse {
 	return successBodyResponse[T]{
 		statusCode: statusCode,
@@ -183,10 +205,43 @@ func BadRequest(err error) ServiceResponse {
 	return ServiceErrorWithMessage(http.StatusBadRequest, err, "Bad Request")
 }
 
+func PreconditionFailed(err error) ServiceResponse {
+	return ServiceErrorWithMessage(http.StatusPreconditionFailed, err, "Precondition Failed")
+}
+
+type headerResponse struct {
+	ServiceResponse
+	key   string
+	value string
+}
+
+func (h headerResponse) WriteResponse(w http.ResponseWriter) {
+	w.Header().Set(h.key, h.value)
+	h.ServiceResponse.WriteResponse(w)
+}
+
+// WithHeader adds a header to a response
+func WithHeader(response ServiceResponse, key string, value string) ServiceResponse {
+	return headerResponse{
+		ServiceResponse: response,
+		key:             key,
+		value:           value,
+	}
+}
+
+// ServiceUnavailable asks the client to retry the request after the given duration
+func ServiceUnavailable(err error, retryAfter time.Duration) ServiceResponse {
+	return WithHeader(ServiceErrorWithMessage(http.StatusServiceUnavailable, err, "Service Unavailable"), "Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+}
+
 func Success[T any](statusCode int, data T) ServiceResponse {
 	return ServiceSuccessBodyResponse(statusCode, data)
 }
 
+func Content(statusCode int, contentType string, content []byte) ServiceResponse {
+	return ServiceContentSuccessResponse(statusCode, contentType, content)
+}
+
 func Ok() ServiceResponse {
 	return ServiceMessageSuccessResponse(http.StatusOK, "OK")
 }
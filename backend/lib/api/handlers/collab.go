@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/bongofriend/bongo-notes/backend/lib/api/services"
+	httputils "github.com/bongofriend/bongo-notes/backend/lib/api/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	collabWriteWait      = 10 * time.Second
+	collabPongWait       = 60 * time.Second
+	collabPingPeriod     = collabPongWait * 9 / 10
+	collabMaxMessageSize = 1024 * 1024
+)
+
+var collabUpgrader = websocket.Upgrader{
+	// Clients authenticate with a token instead of cookies, so connections from other origins are allowed
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+type collabHandler struct {
+	collabService services.CollabService
+}
+
+// Register implements ApiHandler.
+func (c collabHandler) Register(m *ApiMux) {
+	m.AuthenticatedHandlerFunc("GET /notes/{notebookId}/{noteId}/collab", c.Connect)
+}
+
+func NewCollabHandler(s services.ServicesContainer) ApiHandler {
+	return collabHandler{
+		collabService: s.CollabService(),
+	}
+}
+
+// Connect godoc
+//
+//	@Summary	Edit note together with other clients
+//	@Description	Opens a WebSocket exchanging JSON messages. Browsers pass the token as query parameter.
+//	@Description	After connecting, the client receives an init message with the content, revision and connected clients.
+//	@Description	Edits are sent as operation messages with the revision they apply to; the operation is an array of steps:
+//	@Description	positive numbers retain, negative numbers delete and strings insert characters (counted as code points).
+//	@Description	The server answers with ack and the new revision and sends operations of other clients with the revision they apply to.
+//	@Description	cursor messages share selections, join and leave messages announce clients.
+//	@Description	The content is stored as new version periodically and once the last client left, announced by checkpoint messages.
+//	@Description	If the session conflicts with changes made outside of it, they are merged into the session with conflict markers
+//	@Description	sent as operation, and a conflict message is sent instead of a checkpoint. The session is stored once the next checkpoint succeeds.
+//	@Tags		notes
+//	@Router		/notes/{notebookId}/{noteId}/collab [get]
+//	@Param		notebookId	path	string	true	"Id of Notebook which Note is part of"
+//	@Param		noteId		path	string	true	"Id of note to edit"
+//	@Param		token		query	string	false	"Token if the Authorization header cannot be set"
+//	@Success	101
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (c collabHandler) Connect(user models.User, w http.ResponseWriter, r *http.Request) {
+	notebookId, err := uuid.Parse(r.PathValue("notebookId"))
+	if err != nil {
+		log.Println(err)
+		httputils.BadRequestError(w)
+		return
+	}
+	noteId, err := uuid.Parse(r.PathValue("noteId"))
+	if err != nil {
+		log.Println(err)
+		httputils.BadRequestError(w)
+		return
+	}
+	conn, err := c.collabService.Join(user, notebookId, noteId)
+	if errors.Is(err, services.ErrCollabClosed) {
+		log.Println(err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		writeNoteError(w, err)
+		return
+	}
+	ws, err := collabUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		conn.Leave()
+		return
+	}
+	go writeCollabMessages(ws, conn)
+	readCollabMessages(ws, conn)
+}
+
+// readCollabMessages passes messages of the client to the session until the connection is closed
+func readCollabMessages(ws *websocket.Conn, conn *services.CollabConnection) {
+	defer conn.Leave()
+	ws.SetReadLimit(collabMaxMessageSize)
+	ws.SetReadDeadline(time.Now().Add(collabPongWait))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(collabPongWait))
+	})
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Println(err)
+			}
+			return
+		}
+		var msg models.CollabMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			conn.SendError(err)
+			continue
+		}
+		conn.Handle(msg)
+	}
+}
+
+// writeCollabMessages sends messages of the session to the client and closes the connection once the client
+// was disconnected from the session
+func writeCollabMessages(ws *websocket.Conn, conn *services.CollabConnection) {
+	ticker := time.NewTicker(collabPingPeriod)
+	defer func() {
+		ticker.Stop()
+		ws.Close()
+	}()
+	for {
+		select {
+		case msg, ok := <-conn.Messages():
+			ws.SetWriteDeadline(time.Now().Add(collabWriteWait))
+			if !ok {
+				ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := ws.WriteJSON(msg); err != nil {
+				log.Println(err)
+				return
+			}
+		case <-ticker.C:
+			ws.SetWriteDeadline(time.Now().Add(collabWriteWait))
+			if err := ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
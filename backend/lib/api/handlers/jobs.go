@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/bongofriend/bongo-notes/backend/lib/api/services"
+	"github.com/google/uuid"
+)
+
+type jobsHandler struct {
+	jobsService services.JobsService
+}
+
+// Register implements ApiHandler.
+func (j jobsHandler) Register(m *ApiMux) {
+	m.AuthenticatedServiceResponseHandlerFunc("GET /jobs/{jobId}", j.GetJob)
+}
+
+func NewJobsHandler(s services.ServicesContainer) ApiHandler {
+	return jobsHandler{
+		jobsService: s.JobsService(),
+	}
+}
+
+// GetJob godoc
+//
+//	@Summary	Get status of note update
+//	@Description	State is one of queued, running, done, failed (retried at nextAttemptAt) or dead.
+//	@Description	Once done, versionId refers to the version created by the update.
+//	@Tags		jobs
+//	@Router		/jobs/{jobId} [get]
+//	@Param		jobId	path		string	true	"Id of job"
+//	@Success	200		{object}	models.DiffJob
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (j jobsHandler) GetJob(user models.User, r *http.Request) ServiceResponse {
+	jobId, err := uuid.Parse(r.PathValue("jobId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	job, err := j.jobsService.GetJob(user, jobId)
+	if errors.Is(err, services.ErrJobNotFound) {
+		return NotFound(err)
+	}
+	if err != nil {
+		return InternalServerError(err)
+	}
+	return Success(http.StatusOK, job)
+}
+
+// jobAccepted answers with the queued job and its location
+func jobAccepted(job models.DiffJob) ServiceResponse {
+	return WithHeader(Success(http.StatusAccepted, job), "Location", fmt.Sprintf("/jobs/%s", job.Id))
+}
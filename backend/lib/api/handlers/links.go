@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/bongofriend/bongo-notes/backend/lib/api/services"
+	"github.com/google/uuid"
+)
+
+type linksHandler struct {
+	linksService services.LinksService
+}
+
+// Register implements ApiHandler.
+func (l linksHandler) Register(m *ApiMux) {
+	m.AuthenticatedServiceResponseHandlerFunc("GET /notes/{notebookId}/{noteId}/links", l.GetLinks)
+	m.AuthenticatedServiceResponseHandlerFunc("GET /notes/{notebookId}/{noteId}/backlinks", l.GetBacklinks)
+}
+
+func NewLinksHandler(s services.ServicesContainer) ApiHandler {
+	return linksHandler{
+		linksService: s.LinksService(),
+	}
+}
+
+type getLinksResponse struct {
+	Links      []models.NoteLink `json:"links"`
+	Unresolved []string          `json:"unresolved"`
+}
+
+// GetLinks godoc
+//
+//	@Summary	Get wiki links of note
+//	@Description	Links are written as [[Note Title]], [[note-id]] or [[target|label]] and listed in order of appearance. Links not matching any note of the user are listed as unresolved.
+//	@Tags		notes
+//	@Router		/notes/{notebookId}/{noteId}/links [get]
+//	@Param		notebookId	path		string	true	"Id of Notebook which Note is part of"
+//	@Param		noteId		path		string	true	"Id of note"
+//	@Success	200			{object}	handlers.getLinksResponse
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (l linksHandler) GetLinks(user models.User, r *http.Request) ServiceResponse {
+	notebookId, err := uuid.Parse(r.PathValue("notebookId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	noteId, err := uuid.Parse(r.PathValue("noteId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	links, err := l.linksService.GetLinks(user, notebookId, noteId)
+	if err != nil {
+		return noteErrorResponse(err)
+	}
+	response := getLinksResponse{
+		Links:      []models.NoteLink{},
+		Unresolved: []string{},
+	}
+	for _, link := range links {
+		if link.Resolved() {
+			response.Links = append(response.Links, link)
+		} else {
+			response.Unresolved = append(response.Unresolved, link.Target)
+		}
+	}
+	return Success(http.StatusOK, response)
+}
+
+type getBacklinksResponse struct {
+	Backlinks []models.Note `json:"backlinks"`
+}
+
+// GetBacklinks godoc
+//
+//	@Summary	Get notes linking to note
+//	@Description	Lists the notes of the user with a wiki link to the note by its title or id, ordered by title.
+//	@Tags		notes
+//	@Router		/notes/{notebookId}/{noteId}/backlinks [get]
+//	@Param		notebookId	path		string	true	"Id of Notebook which Note is part of"
+//	@Param		noteId		path		string	true	"Id of note"
+//	@Success	200			{object}	handlers.getBacklinksResponse
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (l linksHandler) GetBacklinks(user models.User, r *http.Request) ServiceResponse {
+	notebookId, err := uuid.Parse(r.PathValue("notebookId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	noteId, err := uuid.Parse(r.PathValue("noteId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	backlinks, err := l.linksService.GetBacklinks(user, notebookId, noteId)
+	if err != nil {
+		return noteErrorResponse(err)
+	}
+	return Success(http.StatusOK, getBacklinksResponse{
+		Backlinks: backlinks,
+	})
+}
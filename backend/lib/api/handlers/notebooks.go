@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
 	"github.com/bongofriend/bongo-notes/backend/lib/api/services"
+	"github.com/google/uuid"
 )
 
 type notebooksHandler struct {
@@ -16,6 +18,9 @@ type notebooksHandler struct {
 func (n notebooksHandler) Register(mux *ApiMux) {
 	mux.AuthenticatedServiceResponseHandlerFunc("GET /notebooks", n.GetNotebooks)
 	mux.AuthenticatedServiceResponseHandlerFunc("POST /notebooks", n.CreateNewNotebook)
+	mux.AuthenticatedServiceResponseHandlerFunc("GET /notebooks/{notebookId}", n.GetNotebookDetails)
+	mux.AuthenticatedServiceResponseHandlerFunc("PATCH /notebooks/{notebookId}", n.UpdateNotebookMetadata)
+	mux.AuthenticatedServiceResponseHandlerFunc("PUT /notebooks/order", n.ReorderNotebooks)
 }
 
 func NewNotebooksHandler(services services.ServicesContainer) ApiHandler {
@@ -31,6 +36,7 @@ type getNotebooksResponse struct {
 // GetNotebooks godoc
 //
 //	@Summary	Get notebooks created by user
+//	@Description	Pinned notebooks come first, followed by the user defined order.
 //	@Tags		notebooks
 //	@Router		/notebooks [get]
 //	@Success	200	{object}	handlers.getNotebooksResponse
@@ -76,3 +82,112 @@ func (n notebooksHandler) CreateNewNotebook(user models.User, r *http.Request) S
 	}
 
 }
+
+// GetNotebookDetails godoc
+//
+//	@Summary	Get notebook with statistics about its notes
+//	@Tags		notebooks
+//	@Router		/notebooks/{notebookId} [get]
+//	@Param		notebookId	path		string	true	"Id of notebook"
+//	@Success	200			{object}	models.NotebookDetails
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (n notebooksHandler) GetNotebookDetails(user models.User, r *http.Request) ServiceResponse {
+	notebookId, err := uuid.Parse(r.PathValue("notebookId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	details, err := n.notebooksService.GetNotebookDetails(user, notebookId)
+	if err != nil {
+		return notebookErrorResponse(err)
+	}
+	return Success(http.StatusOK, details)
+}
+
+type updateNotebookMetadataRequest struct {
+	Pinned    *bool   `json:"pinned"`
+	Favourite *bool   `json:"favourite"`
+	Color     *string `json:"color"`
+	Icon      *string `json:"icon"`
+	Emoji     *string `json:"emoji"`
+}
+
+// UpdateNotebookMetadata godoc
+//
+//	@Summary	Update pinned/favourite flags, color, icon or emoji of notebook
+//	@Description	Omitted fields are left unchanged, empty strings clear color, icon or emoji.
+//	@Tags		notebooks
+//	@Router		/notebooks/{notebookId} [patch]
+//	@Param		notebookId	path		string									true	"Id of notebook"
+//	@Param		metadata	body		handlers.updateNotebookMetadataRequest	true	"Metadata to change"
+//	@Success	200			{object}	models.Notebook
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (n notebooksHandler) UpdateNotebookMetadata(user models.User, r *http.Request) ServiceResponse {
+	notebookId, err := uuid.Parse(r.PathValue("notebookId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	var params updateNotebookMetadataRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		return BadRequest(err)
+	}
+	notebook, err := n.notebooksService.UpdateNotebookMetadata(user, notebookId, services.NotebookMetadataUpdate{
+		Pinned:    params.Pinned,
+		Favourite: params.Favourite,
+		Color:     params.Color,
+		Icon:      params.Icon,
+		Emoji:     params.Emoji,
+	})
+	if err != nil {
+		return notebookErrorResponse(err)
+	}
+	return Success(http.StatusOK, notebook)
+}
+
+type reorderNotebooksRequest struct {
+	NotebookIds []uuid.UUID `json:"notebookIds"`
+}
+
+// ReorderNotebooks godoc
+//
+//	@Summary	Set user defined order of notebooks
+//	@Description	Notebooks missing in the list keep their relative order and are placed after the listed ones.
+//	@Tags		notebooks
+//	@Router		/notebooks/order [put]
+//	@Param		order	body	handlers.reorderNotebooksRequest	true	"Notebook ids in new order"
+//	@Success	200
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (n notebooksHandler) ReorderNotebooks(user models.User, r *http.Request) ServiceResponse {
+	var params reorderNotebooksRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		return BadRequest(err)
+	}
+	if err := n.notebooksService.ReorderNotebooks(user, params.NotebookIds); err != nil {
+		return notebookErrorResponse(err)
+	}
+	return Ok()
+}
+
+func notebookErrorResponse(err error) ServiceResponse {
+	switch {
+	case errors.Is(err, services.ErrNotebookNotFound):
+		return NotFound(err)
+	case errors.Is(err, services.ErrInvalidNotebookMetadata):
+		return BadRequest(err)
+	default:
+		return InternalServerError(err)
+	}
+}
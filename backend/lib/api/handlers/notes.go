@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
 	"github.com/bongofriend/bongo-notes/backend/lib/api/services"
@@ -18,9 +22,16 @@ type notesHandler struct {
 // Register implements ApiHandler.
 func (n notesHandler) Register(m *ApiMux) {
 	m.AuthenticatedServiceResponseHandlerFunc("POST /notes/{notebookId}", n.CreateNewNote)
+	m.AuthenticatedServiceResponseHandlerFunc("GET /notes", n.GetNotes)
 	m.AuthenticatedServiceResponseHandlerFunc("GET /notes/{notebookId}", n.GetNotesForNotebook)
 	m.AuthenticatedServiceResponseHandlerFunc("PUT /notes/{notebookId}/{noteId}", n.UpdateNote)
-	m.AuthenticatedHandlerFunc("GET /notes/{notebookId}/{noteid}", n.GetNote)
+	m.AuthenticatedHandlerFunc("GET /notes/{notebookId}/{noteId}", n.GetNote)
+	m.AuthenticatedServiceResponseHandlerFunc("GET /notes/{notebookId}/{noteId}/render", n.RenderNote)
+	m.AuthenticatedServiceResponseHandlerFunc("DELETE /notes/{notebookId}/{noteId}", n.DeleteNote)
+	m.AuthenticatedServiceResponseHandlerFunc("PATCH /notes/{notebookId}/{noteId}", n.UpdateNoteMetadata)
+	m.AuthenticatedServiceResponseHandlerFunc("GET /notes/{notebookId}/{noteId}/versions", n.GetVersions)
+	m.AuthenticatedServiceResponseHandlerFunc("GET /notes/{notebookId}/{noteId}/compare", n.CompareVersions)
+	m.AuthenticatedServiceResponseHandlerFunc("POST /notes/{notebookId}/{noteId}/versions/{versionId}/revert", n.RevertNote)
 }
 
 func NewNotesHandler(s services.ServicesContainer) ApiHandler {
@@ -30,8 +41,10 @@ func NewNotesHandler(s services.ServicesContainer) ApiHandler {
 }
 
 type createNoteRequest struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	Title      string            `json:"title"`
+	Content    string            `json:"content"`
+	TemplateId *uuid.UUID        `json:"templateId"`
+	Variables  map[string]string `json:"variables"`
 }
 
 func postCreateNewNoteParams(r *http.Request) (createNoteRequest, error) {
@@ -46,6 +59,7 @@ func postCreateNewNoteParams(r *http.Request) (createNoteRequest, error) {
 // CreateNewNote godoc
 //
 //	@Summary	Create a new note for a notebook
+//	@Description	If templateId is set, content is ignored and the note is created from the template with its placeholders substituted.
 //	@Tags		notes
 //	@Router		/notes/{notebookId} [post]
 //	@Param		noteParams	body	handlers.createNoteRequest	true	"Parameters for creating a new note"
@@ -53,6 +67,8 @@ func postCreateNewNoteParams(r *http.Request) (createNoteRequest, error) {
 //	@Success	200
 //	@Failure	400
 //	@Failure	401
+//	@Failure	404
+//	@Failure	413
 //	@Failure	500
 //	@Security	BearerAuth
 func (n notesHandler) CreateNewNote(user models.User, r *http.Request) ServiceResponse {
@@ -68,8 +84,14 @@ func (n notesHandler) CreateNewNote(user models.User, r *http.Request) ServiceRe
 	if err != nil {
 		return BadRequest(err)
 	}
+	if params.TemplateId != nil {
+		if err := n.notesService.AddNoteFromTemplate(user, notebookId, *params.TemplateId, params.Title, params.Variables); err != nil {
+			return templateErrorResponse(err)
+		}
+		return Accepted()
+	}
 	if err := n.notesService.AddNoteToNotebook(user, notebookId, params.Title, params.Content); err != nil {
-		return InternalServerError(err)
+		return noteErrorResponse(err)
 	}
 	return Accepted()
 }
@@ -80,10 +102,33 @@ type getNotesForNotebookResponse struct {
 
 // GetNotes godoc
 //
+//	@Summary	Get notes of all notebooks
+//	@Description	Notes are ordered from most to least recently updated. With tags, only notes tagged with all of them are returned.
+//	@Tags		notes
+//	@Router		/notes [get]
+//	@Param		tag	query		[]string	false	"Only include notes with this tag"	collectionFormat(multi)
+//	@Success	200	{object}	handlers.getNotesForNotebookResponse
+//	@Failure	401
+//	@Failure	500
+//	@Security	BearerAuth
+func (n notesHandler) GetNotes(user models.User, r *http.Request) ServiceResponse {
+	notes, err := n.notesService.FetchAllNotes(user, r.URL.Query()["tag"])
+	if err != nil {
+		return InternalServerError(err)
+	}
+	return Success(http.StatusOK, getNotesForNotebookResponse{
+		Notes: notes,
+	})
+}
+
+// GetNotesForNotebook godoc
+//
 //	@Summary	Get notes for notebook
+//	@Description	With tags, only notes tagged with all of them are returned.
 //	@Tags		notes
 //	@Router		/notes/{notebookId} [get]
-//	@Param		notebookId	path		string	true	"Notebook Id for new note"
+//	@Param		notebookId	path		string		true	"Notebook Id for new note"
+//	@Param		tag			query		[]string	false	"Only include notes with this tag"	collectionFormat(multi)
 //	@Success	200			{object}	handlers.getNotesForNotebookResponse
 //	@Failure	400
 //	@Failure	500
@@ -98,7 +143,7 @@ func (n notesHandler) GetNotesForNotebook(user models.User, r *http.Request) Ser
 	if err != nil {
 		return BadRequest(err)
 	}
-	notes, err := n.notesService.FetchNotes(user, notebookId)
+	notes, err := n.notesService.FetchNotes(user, notebookId, r.URL.Query()["tag"])
 	if err != nil {
 		return InternalServerError(err)
 	}
@@ -110,20 +155,40 @@ func (n notesHandler) GetNotesForNotebook(user models.User, r *http.Request) Ser
 
 type updateNoteRequest struct {
 	Content string `json:"content"`
+	// BaseVersionId is the version the content was edited from, changes made since are merged
+	BaseVersionId *uuid.UUID `json:"baseVersionId,omitempty"`
 }
 
 // UpdateNote godoc
 //
 //	@Summary	Update note
+//	@Description	With sync=true or the header "Prefer: wait" (optionally "wait=<seconds>") the request waits for the update to be stored
+//	@Description	and returns the new version. If that takes too long, the job is returned like without waiting.
+//	@Description	With If-Match the update is only stored if the note is still at the given version. A queued update whose
+//	@Description	version was overtaken by another update ends up rejected.
+//	@Description	With baseVersionId in the body, changes made to the note since that version are merged into the content.
+//	@Description	Conflicting changes are reported with 409, or reject the job if they only show up once it is processed.
+//	@Description	If-Match takes precedence over baseVersionId.
+//	@Description	Content has to be UTF-8 text, line endings are converted to \n.
 //	@Tags		notes
 //	@Router		/notes/{notebookId}/{noteId} [put]
 //	@Param		notebookId		path	string						true	"Id of Notebook which Note is part of"
 //	@Param		noteId			path	string						true	"Id of note to update"
 //	@Param		notebookParams	body	handlers.updateNoteRequest	true	"Pramas to update Note conet"
-//	@Success	200
+//	@Param		sync			query	bool						false	"Wait for the update to be stored"
+//	@Param		Prefer			header	string						false	"wait or wait=<seconds> to wait for the update to be stored"
+//	@Param		If-Match		header	string						false	"ETag of the version the update is based on"
+//	@Success	200	{object}	models.NoteUpdateResult
+//	@Header		200	{string}	ETag		"Id of the stored version"
+//	@Success	202	{object}	models.DiffJob
+//	@Header		202	{string}	Location	"Path of the job storing the update"
 //	@Failure	400
 //	@Failure	500
 //	@Failure	401
+//	@Failure	409	{object}	models.MergeConflict
+//	@Failure	412
+//	@Failure	413
+//	@Failure	503
 //	@Security	BearerAuth
 func (n notesHandler) UpdateNote(user models.User, r *http.Request) ServiceResponse {
 	notebookPathId := r.PathValue("notebookId")
@@ -147,23 +212,54 @@ func (n notesHandler) UpdateNote(user models.User, r *http.Request) ServiceRespo
 	if err := decoder.Decode(&reqBody); err != nil {
 		return BadRequest(err)
 	}
-	if err := n.notesService.UpdateNote(user, notebookId, noteId, reqBody.Content); err != nil {
-		return InternalServerError(err)
+	ifMatch, ok := parseIfMatch(r)
+	if !ok {
+		return PreconditionFailed(errors.New("no valid entity tag in If-Match"))
 	}
-	return Accepted()
+	update := services.NoteUpdate{
+		Content:       reqBody.Content,
+		IfMatch:       ifMatch,
+		BaseVersionId: reqBody.BaseVersionId,
+	}
+	if timeout, ok := syncTimeout(r); ok {
+		result, job, err := n.notesService.UpdateNoteAndWait(r.Context(), user, notebookId, noteId, update, timeout)
+		switch {
+		case errors.Is(err, services.ErrUpdatePending):
+			return jobAccepted(job)
+		case errors.Is(err, services.ErrUpdateFailed):
+			return ServiceErrorWithBody(http.StatusInternalServerError, err, job)
+		case errors.Is(err, services.ErrPreconditionFailed) && job.Id != uuid.Nil:
+			return ServiceErrorWithBody(http.StatusPreconditionFailed, err, job)
+		case err != nil:
+			return noteErrorResponse(err)
+		}
+		return WithHeader(Success(http.StatusOK, result), "ETag", etag(result.VersionId))
+	}
+	job, err := n.notesService.UpdateNote(user, notebookId, noteId, update)
+	if err != nil {
+		return noteErrorResponse(err)
+	}
+	return jobAccepted(job)
 }
 
 // GetNote godoc
 //
 //	@Summary	Get note
+//	@Description	Without diff the most recent content is returned, otherwise the content as of the given version.
+//	@Description	Clients preferring text/html over text/plain get the note rendered like by the render endpoint.
 //	@Tags		notes
 //	@Router		/notes/{notebookId}/{noteId} [get]
 //	@Param		notebookId	path	string	true	"Id of Notebook which Note is part of"
 //	@Param		noteId		path	string	true	"Id of note to read"
-//	@Param		diffId		query	string	false	"Id of diff"
+//	@Param		diff		query	string	false	"Id of version"
+//	@Param		Accept		header	string	false	"text/html to render the note"
 //	@Produce plain
+//	@Produce html
 //	@Success	200
+//	@Header		200	{string}	Last-Modified	"Creation time of the requested version"
+//	@Header		200	{string}	ETag			"Id of the returned version"
 //	@Failure	400
+//	@Failure	404
 //	@Failure	500
 //	@Failure	401
 //	@Security	BearerAuth
@@ -180,7 +276,7 @@ func (n notesHandler) GetNote(user models.User, w http.ResponseWriter, r *http.R
 		httputils.BadRequestError(w)
 		return
 	}
-	notePathId := r.PathValue("nodeId")
+	notePathId := r.PathValue("noteId")
 	if len(notePathId) == 0 {
 		log.Println("No noteId found")
 		httputils.BadRequestError(w)
@@ -193,36 +289,476 @@ func (n notesHandler) GetNote(user models.User, w http.ResponseWriter, r *http.R
 		return
 	}
 	diffQueryId := r.URL.Query().Get("diff")
-	if len(diffQueryId) == 0 {
-		content, err := n.notesService.GetNote(user, notebookId, noteId)
+	w.Header().Set("Vary", "Accept")
+	if prefersHTML(r) {
+		versionId, err := optionalVersionId(diffQueryId)
 		if err != nil {
 			log.Println(err)
-			httputils.InternalServerError(w)
+			httputils.BadRequestError(w)
 			return
 		}
+		version, rendered, err := n.notesService.RenderNote(user, notebookId, noteId, versionId)
+		if err != nil {
+			writeNoteError(w, err)
+			return
+		}
+		renderedNoteResponse(version, rendered).WriteResponse(w)
+		return
+	}
+	if len(diffQueryId) == 0 {
+		version, content, err := n.notesService.GetNote(user, notebookId, noteId)
+		if err != nil {
+			writeNoteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("ETag", etag(version.Id))
 		w.WriteHeader(http.StatusOK)
 		if _, err := w.Write(content); err != nil {
 			log.Println(err)
-			httputils.InternalServerError(w)
 		}
 	} else {
-		diffId, err := uuid.Parse(diffQueryId)
+		versionId, err := uuid.Parse(diffQueryId)
 		if err != nil {
 			log.Println(err)
-			httputils.InternalServerError(w)
+			httputils.BadRequestError(w)
 			return
 		}
-		content, err := n.notesService.GetPatchedNote(user, notebookId, noteId, diffId)
+		version, content, err := n.notesService.GetPatchedNote(user, notebookId, noteId, versionId)
 		if err != nil {
-			log.Println(err)
-			httputils.InternalServerError(w)
+			writeNoteError(w, err)
 			return
 		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Last-Modified", version.CreatedAt.UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", etag(version.Id))
 		w.WriteHeader(http.StatusOK)
 		if _, err := w.Write(content); err != nil {
 			log.Println(err)
-			httputils.InternalServerError(w)
 		}
 	}
+}
+
+// RenderNote godoc
+//
+//	@Summary	Render note as Markdown
+//	@Description	Renders GitHub Flavored Markdown with tables, task lists and highlighted code blocks to sanitized HTML.
+//	@Description	Without diff the most recent content is rendered, otherwise the content as of the given version.
+//	@Tags		notes
+//	@Router		/notes/{notebookId}/{noteId}/render [get]
+//	@Param		notebookId	path	string	true	"Id of Notebook which Note is part of"
+//	@Param		noteId		path	string	true	"Id of note to render"
+//	@Param		diff		query	string	false	"Id of version"
+//	@Produce html
+//	@Success	200
+//	@Header		200	{string}	ETag	"Id of the rendered version"
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (n notesHandler) RenderNote(user models.User, r *http.Request) ServiceResponse {
+	notebookId, err := uuid.Parse(r.PathValue("notebookId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	noteId, err := uuid.Parse(r.PathValue("noteId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	versionId, err := optionalVersionId(r.URL.Query().Get("diff"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	version, rendered, err := n.notesService.RenderNote(user, notebookId, noteId, versionId)
+	if err != nil {
+		return noteErrorResponse(err)
+	}
+	return renderedNoteResponse(version, rendered)
+}
+
+func renderedNoteResponse(version models.NoteVersion, rendered []byte) ServiceResponse {
+	response := Content(http.StatusOK, "text/html; charset=utf-8", rendered)
+	// Differs from the tag of the plain content, as both are served under the same URL
+	response = WithHeader(response, "ETag", `"`+version.Id.String()+`-html"`)
+	return WithHeader(response, "Last-Modified", version.CreatedAt.UTC().Format(http.TimeFormat))
+}
+
+func optionalVersionId(value string) (*uuid.UUID, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	versionId, err := uuid.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return &versionId, nil
+}
+
+// prefersHTML reports whether the Accept header ranks text/html above text/plain
+func prefersHTML(r *http.Request) bool {
+	htmlQuality, plainQuality := 0.0, 0.0
+	for _, header := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(header, ",") {
+			mediaType, params, _ := strings.Cut(mediaRange, ";")
+			quality := 1.0
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(param, "=")
+				if strings.TrimSpace(name) == "q" {
+					if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+						quality = q
+					}
+				}
+			}
+			switch strings.ToLower(strings.TrimSpace(mediaType)) {
+			case "text/html":
+				htmlQuality = max(htmlQuality, quality)
+			case "text/plain", "text/*", "*/*":
+				plainQuality = max(plainQuality, quality)
+			}
+		}
+	}
+	return htmlQuality > plainQuality
+}
+
+func writeNoteError(w http.ResponseWriter, err error) {
+	log.Println(err)
+	if errors.Is(err, services.ErrNoteNotFound) || errors.Is(err, services.ErrVersionNotFound) {
+		httputils.NotFoundError(w)
+		return
+	}
+	httputils.InternalServerError(w)
+}
+
+// DeleteNote godoc
+//
+//	@Summary	Delete note with all of its versions
+//	@Tags		notes
+//	@Router		/notes/{notebookId}/{noteId} [delete]
+//	@Param		notebookId	path	string	true	"Id of Notebook which Note is part of"
+//	@Param		noteId		path	string	true	"Id of note to delete"
+//	@Success	200
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (n notesHandler) DeleteNote(user models.User, r *http.Request) ServiceResponse {
+	notebookId, err := uuid.Parse(r.PathValue("notebookId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	noteId, err := uuid.Parse(r.PathValue("noteId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	if err := n.notesService.DeleteNote(user, notebookId, noteId); err != nil {
+		return noteErrorResponse(err)
+	}
+	return Ok()
+}
+
+type updateNoteMetadataRequest struct {
+	Title      *string    `json:"title"`
+	NotebookId *uuid.UUID `json:"notebookId"`
+}
+
+// UpdateNoteMetadata godoc
+//
+//	@Summary	Rename note or move it to another notebook
+//	@Description	Omitted fields are left unchanged.
+//	@Tags		notes
+//	@Router		/notes/{notebookId}/{noteId} [patch]
+//	@Param		notebookId	path		string								true	"Id of Notebook which Note is part of"
+//	@Param		noteId		path		string								true	"Id of note to update"
+//	@Param		metadata	body		handlers.updateNoteMetadataRequest	true	"Metadata to change"
+//	@Success	200			{object}	models.Note
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (n notesHandler) UpdateNoteMetadata(user models.User, r *http.Request) ServiceResponse {
+	notebookId, err := uuid.Parse(r.PathValue("notebookId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	noteId, err := uuid.Parse(r.PathValue("noteId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	var params updateNoteMetadataRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		return BadRequest(err)
+	}
+	note, err := n.notesService.UpdateNoteMetadata(user, notebookId, noteId, services.NoteMetadataUpdate{
+		Title:      params.Title,
+		NotebookId: params.NotebookId,
+	})
+	if err != nil {
+		return noteErrorResponse(err)
+	}
+	return Success(http.StatusOK, note)
+}
+
+const (
+	defaultSyncTimeout = 10 * time.Second
+	maxSyncTimeout     = 60 * time.Second
+)
+
+// syncTimeout returns how long to wait for an update to be stored and false if the client does not want to wait
+func syncTimeout(r *http.Request) (time.Duration, bool) {
+	if sync, _ := strconv.ParseBool(r.URL.Query().Get("sync")); sync {
+		return defaultSyncTimeout, true
+	}
+	for _, header := range r.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			name, value, _ := strings.Cut(preference, "=")
+			if !strings.EqualFold(strings.TrimSpace(name), "wait") {
+				continue
+			}
+			seconds, err := strconv.Atoi(strings.Trim(strings.TrimSpace(value), `"`))
+			if err != nil || seconds <= 0 {
+				return defaultSyncTimeout, true
+			}
+			return min(time.Duration(seconds)*time.Second, maxSyncTimeout), true
+		}
+	}
+	return 0, false
+}
 
+// etag returns the entity tag of a note version
+func etag(versionId uuid.UUID) string {
+	return `"` + versionId.String() + `"`
+}
+
+// parseIfMatch returns the versions listed in the If-Match header. An empty list means the update is not conditional,
+// false is returned if the header is present without any tag which could match a version.
+func parseIfMatch(r *http.Request) ([]uuid.UUID, bool) {
+	headers := r.Header.Values("If-Match")
+	if len(headers) == 0 {
+		return nil, true
+	}
+	var versionIds []uuid.UUID
+	for _, header := range headers {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" {
+				return nil, true
+			}
+			// Weak tags never match, as If-Match requires strong comparison
+			if strings.HasPrefix(tag, "W/") {
+				continue
+			}
+			versionId, err := uuid.Parse(strings.Trim(tag, `"`))
+			if err != nil {
+				continue
+			}
+			versionIds = append(versionIds, versionId)
+		}
+	}
+	return versionIds, len(versionIds) > 0
+}
+
+// validationErrorResponse explains why the content of a note was rejected
+func validationErrorResponse(err error) ServiceResponse {
+	if errors.Is(err, services.ErrNoteTooLarge) {
+		return ServiceErrorWithMessage(http.StatusRequestEntityTooLarge, err, err.Error())
+	}
+	return ServiceErrorWithMessage(http.StatusBadRequest, err, err.Error())
+}
+
+// queueFullRetryAfter is the delay after which clients should retry updates rejected due to a full queue
+const queueFullRetryAfter = 5 * time.Second
+
+const (
+	defaultVersionsLimit = 50
+	maxVersionsLimit     = 500
+)
+
+type getVersionsResponse struct {
+	Versions   []models.NoteVersion `json:"versions"`
+	NextCursor *uuid.UUID           `json:"nextCursor,omitempty"`
+}
+
+// GetVersions godoc
+//
+//	@Summary	Get versions of note
+//	@Description	Versions are ordered from newest to oldest. If nextCursor is set, it can be passed as cursor to get the next page.
+//	@Tags		notes
+//	@Router		/notes/{notebookId}/{noteId}/versions [get]
+//	@Param		notebookId	path		string	true	"Id of Notebook which Note is part of"
+//	@Param		noteId		path		string	true	"Id of note"
+//	@Param		cursor		query		string	false	"nextCursor of previous page"
+//	@Param		limit		query		int		false	"Maximum number of versions, defaults to 50"
+//	@Success	200			{object}	handlers.getVersionsResponse
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (n notesHandler) GetVersions(user models.User, r *http.Request) ServiceResponse {
+	notebookId, err := uuid.Parse(r.PathValue("notebookId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	noteId, err := uuid.Parse(r.PathValue("noteId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	var cursor *uuid.UUID
+	if cursorQuery := r.URL.Query().Get("cursor"); len(cursorQuery) > 0 {
+		id, err := uuid.Parse(cursorQuery)
+		if err != nil {
+			return BadRequest(err)
+		}
+		cursor = &id
+	}
+	limit := defaultVersionsLimit
+	if limitQuery := r.URL.Query().Get("limit"); len(limitQuery) > 0 {
+		limit, err = strconv.Atoi(limitQuery)
+		if err != nil || limit <= 0 || limit > maxVersionsLimit {
+			return BadRequest(err)
+		}
+	}
+	versions, nextCursor, err := n.notesService.ListVersions(user, notebookId, noteId, cursor, limit)
+	if err != nil {
+		return noteErrorResponse(err)
+	}
+	return Success(http.StatusOK, getVersionsResponse{
+		Versions:   versions,
+		NextCursor: nextCursor,
+	})
+}
+
+const (
+	defaultContextLines = 3
+	maxContextLines     = 100
+)
+
+// CompareVersions godoc
+//
+//	@Summary	Compare two versions of a note
+//	@Description	Returns a unified diff together with its hunks. With "Accept: text/x-diff" only the unified diff is returned.
+//	@Tags		notes
+//	@Router		/notes/{notebookId}/{noteId}/compare [get]
+//	@Param		notebookId	path		string	true	"Id of Notebook which Note is part of"
+//	@Param		noteId		path		string	true	"Id of note"
+//	@Param		from		query		string	true	"Id of version or current"
+//	@Param		to			query		string	false	"Id of version or current, defaults to current"
+//	@Param		context		query		int		false	"Number of context lines, defaults to 3"
+//	@Produce	json
+//	@Produce	text/x-diff
+//	@Success	200			{object}	models.NoteComparison
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (n notesHandler) CompareVersions(user models.User, r *http.Request) ServiceResponse {
+	notebookId, err := uuid.Parse(r.PathValue("notebookId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	noteId, err := uuid.Parse(r.PathValue("noteId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	query := r.URL.Query()
+	if !query.Has("from") {
+		return BadRequest(errors.New("from is required"))
+	}
+	from, err := parseVersionRef(query.Get("from"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	to, err := parseVersionRef(query.Get("to"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	contextLines := defaultContextLines
+	if contextQuery := query.Get("context"); len(contextQuery) > 0 {
+		contextLines, err = strconv.Atoi(contextQuery)
+		if err != nil || contextLines < 0 || contextLines > maxContextLines {
+			return BadRequest(err)
+		}
+	}
+	comparison, err := n.notesService.CompareVersions(user, notebookId, noteId, from, to, contextLines)
+	if err != nil {
+		return noteErrorResponse(err)
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/x-diff") {
+		return Content(http.StatusOK, "text/x-diff; charset=utf-8", []byte(comparison.Unified))
+	}
+	return Success(http.StatusOK, comparison)
+}
+
+// RevertNote godoc
+//
+//	@Summary	Revert note to an earlier version
+//	@Description	The content of the version is saved as a new version, so a revert can be undone.
+//	@Tags		notes
+//	@Router		/notes/{notebookId}/{noteId}/versions/{versionId}/revert [post]
+//	@Param		notebookId	path	string	true	"Id of Notebook which Note is part of"
+//	@Param		noteId		path	string	true	"Id of note"
+//	@Param		versionId	path	string	true	"Id of version to revert to"
+//	@Success	202	{object}	models.DiffJob
+//	@Header		202	{string}	Location	"Path of the job storing the revert"
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Failure	503
+//	@Security	BearerAuth
+func (n notesHandler) RevertNote(user models.User, r *http.Request) ServiceResponse {
+	notebookId, err := uuid.Parse(r.PathValue("notebookId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	noteId, err := uuid.Parse(r.PathValue("noteId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	versionId, err := uuid.Parse(r.PathValue("versionId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	job, err := n.notesService.RevertNote(user, notebookId, noteId, versionId)
+	if err != nil {
+		return noteErrorResponse(err)
+	}
+	return jobAccepted(job)
+}
+
+// parseVersionRef returns nil for the current version
+func parseVersionRef(ref string) (*uuid.UUID, error) {
+	if len(ref) == 0 || ref == services.CurrentVersion {
+		return nil, nil
+	}
+	id, err := uuid.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func noteErrorResponse(err error) ServiceResponse {
+	var conflictErr services.MergeConflictError
+	switch {
+	case errors.As(err, &conflictErr):
+		return ServiceErrorWithBody(http.StatusConflict, err, conflictErr.Conflict)
+	case errors.Is(err, services.ErrNoteNotFound), errors.Is(err, services.ErrNotebookNotFound), errors.Is(err, services.ErrVersionNotFound):
+		return NotFound(err)
+	case errors.Is(err, services.ErrInvalidNoteMetadata), errors.Is(err, services.ErrInvalidCursor):
+		return BadRequest(err)
+	case errors.Is(err, services.ErrQueueFull):
+		return ServiceUnavailable(err, queueFullRetryAfter)
+	case errors.Is(err, services.ErrPreconditionFailed):
+		return PreconditionFailed(err)
+	case errors.Is(err, services.ErrInvalidNoteContent), errors.Is(err, services.ErrNoteTooLarge):
+		return validationErrorResponse(err)
+	default:
+		return InternalServerError(err)
+	}
 }
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/bongofriend/bongo-notes/backend/lib/api/services"
+	"github.com/bongofriend/bongo-notes/backend/lib/search"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+type searchHandler struct {
+	searchService services.SearchService
+}
+
+// Register implements ApiHandler.
+func (s searchHandler) Register(m *ApiMux) {
+	m.AuthenticatedServiceResponseHandlerFunc("GET /search", s.Search)
+}
+
+func NewSearchHandler(c services.ServicesContainer) ApiHandler {
+	return searchHandler{
+		searchService: c.SearchService(),
+	}
+}
+
+type searchResponse struct {
+	Results []models.SearchResult `json:"results"`
+}
+
+type searchQueryErrorResponse struct {
+	Error string `json:"error"`
+	// Position is the byte offset of the invalid term in the query
+	Position int    `json:"position"`
+	Token    string `json:"token"`
+}
+
+// Search godoc
+//
+//	@Summary	Search notes
+//	@Description	Finds notes of all notebooks of the user matching every term of the query. Terms are separated by whitespace:
+//	@Description	words or "quoted phrases" match the title or content, a trailing * matches words starting with the term.
+//	@Description	title:, content:, tag:, notebook: and updated: or created: (a date or time, optionally prefixed by >, >=, < or <=) filter notes.
+//	@Description	A leading - excludes notes matching a term, e.g. tag:ops notebook:"Runbooks" updated:>2026-01-01 title:deploy -draft
+//	@Description	Results are ordered from best to worst match, matches in the title weigh more.
+//	@Description	highlightedTitle and snippet are HTML with matched terms wrapped in mark elements.
+//	@Tags		search
+//	@Router		/search [get]
+//	@Param		q		query		string	true	"Search terms"
+//	@Param		limit	query		int		false	"Maximum number of results, defaults to 20"
+//	@Param		offset	query		int		false	"Number of results to skip"
+//	@Success	200		{object}	handlers.searchResponse
+//	@Failure	400		{object}	handlers.searchQueryErrorResponse
+//	@Failure	401
+//	@Failure	500
+//	@Security	BearerAuth
+func (s searchHandler) Search(user models.User, r *http.Request) ServiceResponse {
+	var err error
+	limit := defaultSearchLimit
+	if limitQuery := r.URL.Query().Get("limit"); len(limitQuery) > 0 {
+		limit, err = strconv.Atoi(limitQuery)
+		if err != nil || limit <= 0 || limit > maxSearchLimit {
+			return BadRequest(err)
+		}
+	}
+	offset := 0
+	if offsetQuery := r.URL.Query().Get("offset"); len(offsetQuery) > 0 {
+		offset, err = strconv.Atoi(offsetQuery)
+		if err != nil || offset < 0 {
+			return BadRequest(err)
+		}
+	}
+	results, err := s.searchService.Search(user, r.URL.Query().Get("q"), limit, offset)
+	var syntaxErr *search.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return ServiceErrorWithBody(http.StatusBadRequest, err, searchQueryErrorResponse{
+			Error:    syntaxErr.Error(),
+			Position: syntaxErr.Position,
+			Token:    syntaxErr.Token,
+		})
+	}
+	if err != nil {
+		return InternalServerError(err)
+	}
+	return Success(http.StatusOK, searchResponse{
+		Results: results,
+	})
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/bongofriend/bongo-notes/backend/lib/api/services"
+	"github.com/google/uuid"
+)
+
+type tagsHandler struct {
+	tagsService services.TagsService
+}
+
+// Register implements ApiHandler.
+func (t tagsHandler) Register(m *ApiMux) {
+	m.AuthenticatedServiceResponseHandlerFunc("GET /tags", t.GetTags)
+	m.AuthenticatedServiceResponseHandlerFunc("PATCH /tags/{tagId}", t.RenameTag)
+	m.AuthenticatedServiceResponseHandlerFunc("POST /tags/{tagId}/merge", t.MergeTag)
+	m.AuthenticatedServiceResponseHandlerFunc("DELETE /tags/{tagId}", t.DeleteTag)
+	m.AuthenticatedServiceResponseHandlerFunc("POST /notes/{notebookId}/{noteId}/tags", t.TagNote)
+	m.AuthenticatedServiceResponseHandlerFunc("DELETE /notes/{notebookId}/{noteId}/tags/{tag}", t.UntagNote)
+}
+
+func NewTagsHandler(s services.ServicesContainer) ApiHandler {
+	return tagsHandler{
+		tagsService: s.TagsService(),
+	}
+}
+
+type getTagsResponse struct {
+	Tags []models.Tag `json:"tags"`
+}
+
+// GetTags godoc
+//
+//	@Summary	Get tags of user
+//	@Description	Tags are ordered by name and include the number of notes tagged with them.
+//	@Tags		tags
+//	@Router		/tags [get]
+//	@Success	200	{object}	handlers.getTagsResponse
+//	@Failure	401
+//	@Failure	500
+//	@Security	BearerAuth
+func (t tagsHandler) GetTags(user models.User, r *http.Request) ServiceResponse {
+	tags, err := t.tagsService.FetchTags(user)
+	if err != nil {
+		return InternalServerError(err)
+	}
+	return Success(http.StatusOK, getTagsResponse{
+		Tags: tags,
+	})
+}
+
+type renameTagRequest struct {
+	Name string `json:"name"`
+}
+
+// RenameTag godoc
+//
+//	@Summary	Rename tag
+//	@Description	The tag is renamed on all notes. If another tag already has the name, 409 is returned and the tags can be merged instead.
+//	@Tags		tags
+//	@Router		/tags/{tagId} [patch]
+//	@Param		tagId		path		string						true	"Id of tag"
+//	@Param		tagParams	body		handlers.renameTagRequest	true	"New name of tag"
+//	@Success	200			{object}	models.Tag
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	409
+//	@Failure	500
+//	@Security	BearerAuth
+func (t tagsHandler) RenameTag(user models.User, r *http.Request) ServiceResponse {
+	tagId, err := uuid.Parse(r.PathValue("tagId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	var params renameTagRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		return BadRequest(err)
+	}
+	tag, err := t.tagsService.RenameTag(user, tagId, params.Name)
+	if err != nil {
+		return tagErrorResponse(err)
+	}
+	return Success(http.StatusOK, tag)
+}
+
+type mergeTagRequest struct {
+	TargetId uuid.UUID `json:"targetId"`
+}
+
+// MergeTag godoc
+//
+//	@Summary	Merge tag into another tag
+//	@Description	All notes tagged with the tag are tagged with the target tag instead, then the tag is deleted.
+//	@Tags		tags
+//	@Router		/tags/{tagId}/merge [post]
+//	@Param		tagId		path		string					true	"Id of tag to merge"
+//	@Param		mergeParams	body		handlers.mergeTagRequest	true	"Id of tag to merge into"
+//	@Success	200			{object}	models.Tag
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (t tagsHandler) MergeTag(user models.User, r *http.Request) ServiceResponse {
+	tagId, err := uuid.Parse(r.PathValue("tagId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	var params mergeTagRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		return BadRequest(err)
+	}
+	tag, err := t.tagsService.MergeTag(user, tagId, params.TargetId)
+	if err != nil {
+		return tagErrorResponse(err)
+	}
+	return Success(http.StatusOK, tag)
+}
+
+// DeleteTag godoc
+//
+//	@Summary	Delete tag
+//	@Description	The tag is removed from all notes.
+//	@Tags		tags
+//	@Router		/tags/{tagId} [delete]
+//	@Param		tagId	path	string	true	"Id of tag"
+//	@Success	200
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (t tagsHandler) DeleteTag(user models.User, r *http.Request) ServiceResponse {
+	tagId, err := uuid.Parse(r.PathValue("tagId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	if err := t.tagsService.DeleteTag(user, tagId); err != nil {
+		return tagErrorResponse(err)
+	}
+	return Ok()
+}
+
+type noteTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type noteTagsResponse struct {
+	Tags []string `json:"tags"`
+}
+
+// TagNote godoc
+//
+//	@Summary	Add tags to note
+//	@Description	Tags are matched regardless of case, tags that do not exist yet are created. Returns all tags of the note.
+//	@Tags		tags
+//	@Router		/notes/{notebookId}/{noteId}/tags [post]
+//	@Param		notebookId	path		string						true	"Id of Notebook which Note is part of"
+//	@Param		noteId		path		string						true	"Id of note"
+//	@Param		tagParams	body		handlers.noteTagsRequest	true	"Tags to add"
+//	@Success	200			{object}	handlers.noteTagsResponse
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (t tagsHandler) TagNote(user models.User, r *http.Request) ServiceResponse {
+	notebookId, err := uuid.Parse(r.PathValue("notebookId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	noteId, err := uuid.Parse(r.PathValue("noteId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	var params noteTagsRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		return BadRequest(err)
+	}
+	tags, err := t.tagsService.TagNote(user, notebookId, noteId, params.Tags)
+	if err != nil {
+		return tagErrorResponse(err)
+	}
+	return Success(http.StatusOK, noteTagsResponse{
+		Tags: tags,
+	})
+}
+
+// UntagNote godoc
+//
+//	@Summary	Remove tag from note
+//	@Description	Returns the remaining tags of the note. The tag itself is kept, even if no note is tagged with it anymore.
+//	@Tags		tags
+//	@Router		/notes/{notebookId}/{noteId}/tags/{tag} [delete]
+//	@Param		notebookId	path		string	true	"Id of Notebook which Note is part of"
+//	@Param		noteId		path		string	true	"Id of note"
+//	@Param		tag			path		string	true	"Name of tag"
+//	@Success	200			{object}	handlers.noteTagsResponse
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (t tagsHandler) UntagNote(user models.User, r *http.Request) ServiceResponse {
+	notebookId, err := uuid.Parse(r.PathValue("notebookId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	noteId, err := uuid.Parse(r.PathValue("noteId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	tags, err := t.tagsService.UntagNote(user, notebookId, noteId, r.PathValue("tag"))
+	if err != nil {
+		return tagErrorResponse(err)
+	}
+	return Success(http.StatusOK, noteTagsResponse{
+		Tags: tags,
+	})
+}
+
+func tagErrorResponse(err error) ServiceResponse {
+	switch {
+	case errors.Is(err, services.ErrTagNotFound), errors.Is(err, services.ErrNoteNotFound):
+		return NotFound(err)
+	case errors.Is(err, services.ErrInvalidTag):
+		return ServiceErrorWithMessage(http.StatusBadRequest, err, err.Error())
+	case errors.Is(err, services.ErrTagExists):
+		return ServiceErrorWithMessage(http.StatusConflict, err, err.Error())
+	default:
+		return InternalServerError(err)
+	}
+}
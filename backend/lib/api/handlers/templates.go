@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/bongofriend/bongo-notes/backend/lib/api/services"
+	"github.com/google/uuid"
+)
+
+type templatesHandler struct {
+	templatesService services.TemplatesService
+}
+
+// Register implements ApiHandler.
+func (t templatesHandler) Register(m *ApiMux) {
+	m.AuthenticatedServiceResponseHandlerFunc("GET /templates", t.GetTemplates)
+	m.AuthenticatedServiceResponseHandlerFunc("POST /templates", t.CreateTemplate)
+	m.AuthenticatedServiceResponseHandlerFunc("GET /templates/{templateId}", t.GetTemplate)
+	m.AuthenticatedServiceResponseHandlerFunc("PUT /templates/{templateId}", t.UpdateTemplate)
+	m.AuthenticatedServiceResponseHandlerFunc("DELETE /templates/{templateId}", t.DeleteTemplate)
+}
+
+func NewTemplatesHandler(s services.ServicesContainer) ApiHandler {
+	return templatesHandler{
+		templatesService: s.TemplatesService(),
+	}
+}
+
+type getTemplatesResponse struct {
+	Templates []models.NoteTemplate `json:"templates"`
+}
+
+// GetTemplates godoc
+//
+//	@Summary	Get note templates of user
+//	@Tags		templates
+//	@Router		/templates [get]
+//	@Param		notebookId	query		string	false	"Only include templates usable in this notebook"
+//	@Success	200			{object}	handlers.getTemplatesResponse
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (t templatesHandler) GetTemplates(user models.User, r *http.Request) ServiceResponse {
+	var notebookId *uuid.UUID
+	if notebookQueryId := r.URL.Query().Get("notebookId"); len(notebookQueryId) > 0 {
+		id, err := uuid.Parse(notebookQueryId)
+		if err != nil {
+			return BadRequest(err)
+		}
+		notebookId = &id
+	}
+	templates, err := t.templatesService.FetchTemplates(user, notebookId)
+	if err != nil {
+		return templateErrorResponse(err)
+	}
+	return Success(http.StatusOK, getTemplatesResponse{
+		Templates: templates,
+	})
+}
+
+type templateRequest struct {
+	NotebookId *uuid.UUID `json:"notebookId"`
+	Name       string     `json:"name"`
+	Title      string     `json:"title"`
+	Content    string     `json:"content"`
+}
+
+// CreateTemplate godoc
+//
+//	@Summary	Create note template
+//	@Description	Title and content may contain placeholders like {{date}}, {{time}}, {{title}}, {{username}} or custom variables.
+//	@Description	Templates with a notebookId are only usable in that notebook.
+//	@Tags		templates
+//	@Router		/templates [post]
+//	@Param		templateParams	body		handlers.templateRequest	true	"Parameters for creating a new template"
+//	@Success	201				{object}	models.NoteTemplate
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (t templatesHandler) CreateTemplate(user models.User, r *http.Request) ServiceResponse {
+	var params templateRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		return BadRequest(err)
+	}
+	template, err := t.templatesService.CreateTemplate(user, params.NotebookId, params.Name, params.Title, params.Content)
+	if err != nil {
+		return templateErrorResponse(err)
+	}
+	return Success(http.StatusCreated, template)
+}
+
+// GetTemplate godoc
+//
+//	@Summary	Get note template
+//	@Tags		templates
+//	@Router		/templates/{templateId} [get]
+//	@Param		templateId	path		string	true	"Id of template"
+//	@Success	200			{object}	models.NoteTemplate
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (t templatesHandler) GetTemplate(user models.User, r *http.Request) ServiceResponse {
+	templateId, err := uuid.Parse(r.PathValue("templateId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	template, err := t.templatesService.GetTemplate(user, templateId)
+	if err != nil {
+		return templateErrorResponse(err)
+	}
+	return Success(http.StatusOK, template)
+}
+
+// UpdateTemplate godoc
+//
+//	@Summary	Update note template
+//	@Tags		templates
+//	@Router		/templates/{templateId} [put]
+//	@Param		templateId		path	string						true	"Id of template"
+//	@Param		templateParams	body	handlers.templateRequest	true	"New name, title and content of template"
+//	@Success	200
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (t templatesHandler) UpdateTemplate(user models.User, r *http.Request) ServiceResponse {
+	templateId, err := uuid.Parse(r.PathValue("templateId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	var params templateRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		return BadRequest(err)
+	}
+	if err := t.templatesService.UpdateTemplate(user, templateId, params.Name, params.Title, params.Content); err != nil {
+		return templateErrorResponse(err)
+	}
+	return Ok()
+}
+
+// DeleteTemplate godoc
+//
+//	@Summary	Delete note template
+//	@Tags		templates
+//	@Router		/templates/{templateId} [delete]
+//	@Param		templateId	path	string	true	"Id of template"
+//	@Success	200
+//	@Failure	400
+//	@Failure	401
+//	@Failure	404
+//	@Failure	500
+//	@Security	BearerAuth
+func (t templatesHandler) DeleteTemplate(user models.User, r *http.Request) ServiceResponse {
+	templateId, err := uuid.Parse(r.PathValue("templateId"))
+	if err != nil {
+		return BadRequest(err)
+	}
+	if err := t.templatesService.DeleteTemplate(user, templateId); err != nil {
+		return templateErrorResponse(err)
+	}
+	return Ok()
+}
+
+func templateErrorResponse(err error) ServiceResponse {
+	switch {
+	case errors.Is(err, services.ErrTemplateNotFound), errors.Is(err, services.ErrNotebookNotFound):
+		return NotFound(err)
+	case errors.Is(err, services.ErrInvalidTemplate):
+		return BadRequest(err)
+	case errors.Is(err, services.ErrInvalidNoteContent), errors.Is(err, services.ErrNoteTooLarge):
+		return validationErrorResponse(err)
+	default:
+		return InternalServerError(err)
+	}
+}
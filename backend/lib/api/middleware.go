@@ -1,7 +1,9 @@
 package api
 
 import (
+	"bufio"
 	"log"
+	"net"
 	"net/http"
 	"time"
 )
@@ -18,6 +20,19 @@ func (r *responseWithStatusCode) WriteHeader(status int) {
 	r.statusCode = status
 }
 
+// Hijack lets WebSocket connections take over the connection
+func (r *responseWithStatusCode) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.statusCode = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (r *responseWithStatusCode) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 func Logger(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
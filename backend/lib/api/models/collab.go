@@ -0,0 +1,55 @@
+package models
+
+import (
+	"github.com/bongofriend/bongo-notes/backend/lib/ot"
+	"github.com/google/uuid"
+)
+
+type CollabMessageType string
+
+const (
+	// CollabInit is sent to a client after joining with the content, revision and connected clients
+	CollabInit CollabMessageType = "init"
+	// CollabOperation is an edit sent by a client or broadcast to the other clients
+	CollabOperation CollabMessageType = "operation"
+	// CollabAck confirms an operation of a client with the revision it was stored as
+	CollabAck CollabMessageType = "ack"
+	// CollabCursor is a changed selection of a client
+	CollabCursor     CollabMessageType = "cursor"
+	CollabJoin       CollabMessageType = "join"
+	CollabLeave      CollabMessageType = "leave"
+	CollabCheckpoint CollabMessageType = "checkpoint"
+	// CollabConflict is sent instead of a checkpoint if the session conflicts with changes made outside of it.
+	// The changes are merged into the session with conflict markers, VersionId is the version they come from.
+	CollabConflict CollabMessageType = "conflict"
+	CollabError    CollabMessageType = "error"
+)
+
+// CollabMessage is exchanged between the server and clients editing a note together. Operations and
+// cursors refer to the document as of Revision. Fields not needed by a type are left out.
+type CollabMessage struct {
+	Type      CollabMessageType `json:"type"`
+	Revision  int               `json:"revision"`
+	ClientId  *uuid.UUID        `json:"clientId,omitempty"`
+	Operation *ot.Operation     `json:"operation,omitempty"`
+	Cursor    *Cursor           `json:"cursor,omitempty"`
+	Content   *string           `json:"content,omitempty"`
+	VersionId *uuid.UUID        `json:"versionId,omitempty"`
+	Clients   []CollabClient    `json:"clients,omitempty"`
+	Client    *CollabClient     `json:"client,omitempty"`
+	Message   string            `json:"message,omitempty"`
+}
+
+// Cursor is a selection in a document, both ends are equal if nothing is selected
+type Cursor struct {
+	Anchor int `json:"anchor"`
+	Head   int `json:"head"`
+}
+
+// CollabClient is the presence of a connected editor
+type CollabClient struct {
+	ClientId uuid.UUID `json:"clientId"`
+	UserId   uuid.UUID `json:"userId"`
+	Username string    `json:"username"`
+	Cursor   *Cursor   `json:"cursor,omitempty"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type JobState string
+
+const (
+	JobQueued  JobState = "queued"
+	JobRunning JobState = "running"
+	JobDone    JobState = "done"
+	// JobFailed jobs are retried once NextAttemptAt is reached
+	JobFailed JobState = "failed"
+	// JobDead jobs failed too often and are not retried anymore
+	JobDead JobState = "dead"
+	// JobRejected jobs were not stored because the note changed after the version they were based on
+	// and the changes could not be merged
+	JobRejected JobState = "rejected"
+)
+
+// Finished reports whether a job will not be processed anymore
+func (j DiffJob) Finished() bool {
+	return j.State == JobDone || j.State == JobDead || j.State == JobRejected
+}
+
+// DiffJob is an update of a note waiting to be stored as a new version
+type DiffJob struct {
+	Id        uuid.UUID  `json:"id"`
+	NoteId    uuid.UUID  `json:"noteId"`
+	AuthorId  uuid.UUID  `json:"authorId"`
+	State     JobState   `json:"state"`
+	Attempts  int        `json:"attempts"`
+	Error     string     `json:"error,omitempty"`
+	VersionId *uuid.UUID `json:"versionId,omitempty"`
+	// BaseVersionId is the version the note must still be at when the job is processed.
+	// With Merge, changes made since the base version are merged with the content instead.
+	BaseVersionId *uuid.UUID `json:"baseVersionId,omitempty"`
+	Merge         bool       `json:"merge,omitempty"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// NoteUpdateResult describes the version stored by an update that was waited for
+type NoteUpdateResult struct {
+	JobId     uuid.UUID `json:"jobId"`
+	VersionId uuid.UUID `json:"versionId"`
+	Version   int       `json:"version"`
+	// CreatedAt is the creation time of the version and UpdatedAt the last modification of the note
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// ContentHash is the hex encoded SHA-256 hash of the stored content
+	ContentHash string `json:"contentHash"`
+}
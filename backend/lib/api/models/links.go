@@ -0,0 +1,18 @@
+package models
+
+import "github.com/google/uuid"
+
+// NoteLink is a wiki link from one note to another
+type NoteLink struct {
+	// Target is the title or id the link was written with
+	Target string `json:"target"`
+	// NoteId, NotebookId and Title describe the linked note, they are left out for unresolved links
+	NoteId     *uuid.UUID `json:"noteId,omitempty"`
+	NotebookId *uuid.UUID `json:"notebookId,omitempty"`
+	Title      string     `json:"title,omitempty"`
+}
+
+// Resolved reports whether a note matches the target of a link
+func (l NoteLink) Resolved() bool {
+	return l.NoteId != nil
+}
@@ -1,9 +1,34 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Notebook struct {
 	Id          uuid.UUID `json:"id"`
 	Title       string    `json:"title"`
-	Description string    `json:"descripton"`
+	Description string    `json:"description"`
+	SortOrder   int       `json:"sortOrder"`
+	NotebookMetadata
+}
+
+type NotebookMetadata struct {
+	Pinned    bool   `json:"pinned"`
+	Favourite bool   `json:"favourite"`
+	Color     string `json:"color,omitempty"`
+	Icon      string `json:"icon,omitempty"`
+	Emoji     string `json:"emoji,omitempty"`
+}
+
+type NotebookDetails struct {
+	Notebook
+	NoteCount        int        `json:"noteCount"`
+	TotalSize        int64      `json:"totalSize"`
+	VersionCount     int        `json:"versionCount"`
+	LastModifiedNote *Note      `json:"lastModifiedNote,omitempty"`
+	LastModifiedAt   *time.Time `json:"lastModifiedAt,omitempty"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	UpdatedAt        time.Time  `json:"updatedAt"`
 }
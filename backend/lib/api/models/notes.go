@@ -1,8 +1,18 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Note struct {
-	Id    uuid.UUID `json:"id"`
-	Title string    `json:"Title"`
+	Id           uuid.UUID `json:"id"`
+	NotebookId   uuid.UUID `json:"notebookId"`
+	Title        string    `json:"title"`
+	Tags         []string  `json:"tags"`
+	Size         int64     `json:"size"`
+	VersionCount int       `json:"versionCount"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
 }
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SearchResult is a note matching a search query
+type SearchResult struct {
+	NoteId        uuid.UUID `json:"noteId"`
+	NotebookId    uuid.UUID `json:"notebookId"`
+	NotebookTitle string    `json:"notebookTitle"`
+	Title         string    `json:"title"`
+	// HighlightedTitle and Snippet are HTML with matched terms wrapped in mark elements
+	HighlightedTitle string `json:"highlightedTitle"`
+	Snippet          string `json:"snippet"`
+	// Score orders the results, higher scores match better
+	Score     float64   `json:"score"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Tag struct {
+	Id        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	NoteCount int       `json:"noteCount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
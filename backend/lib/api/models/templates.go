@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NoteTemplate struct {
+	Id         uuid.UUID  `json:"id"`
+	NotebookId *uuid.UUID `json:"notebookId,omitempty"`
+	Name       string     `json:"name"`
+	Title      string     `json:"title"`
+	Content    string     `json:"content"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/diff"
+	"github.com/google/uuid"
+)
+
+// NoteVersion identifies the content of a note after it was created or updated
+type NoteVersion struct {
+	Id        uuid.UUID  `json:"id"`
+	Version   int        `json:"version"`
+	CreatedAt time.Time  `json:"createdAt"`
+	AuthorId  *uuid.UUID `json:"authorId,omitempty"`
+	Author    string     `json:"author,omitempty"`
+	VersionStats
+}
+
+type VersionStats struct {
+	Size         int64 `json:"size"`
+	LineCount    int   `json:"lineCount"`
+	LinesAdded   int   `json:"linesAdded"`
+	LinesRemoved int   `json:"linesRemoved"`
+	DiffSize     int64 `json:"diffSize"`
+}
+
+// NoteComparison describes the changes between two versions of a note
+type NoteComparison struct {
+	From    string      `json:"from"`
+	To      string      `json:"to"`
+	Unified string      `json:"unified"`
+	Hunks   []diff.Hunk `json:"hunks"`
+}
+
+// NoteSnapshot is the full content of a version stored to avoid long chains of diffs
+type NoteSnapshot struct {
+	VersionId uuid.UUID `json:"versionId"`
+	Version   int       `json:"version"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// MergeConflict describes an update that could not be merged with the changes made since its base version
+type MergeConflict struct {
+	BaseVersionId    uuid.UUID `json:"baseVersionId"`
+	CurrentVersionId uuid.UUID `json:"currentVersionId"`
+	// Content is the merged content with conflict markers around every conflict
+	Content   string          `json:"content"`
+	Conflicts []diff.Conflict `json:"conflicts"`
+}
@@ -51,6 +51,10 @@ func (a authServiceImpl) Authenticate(r *http.Request) (models.User, bool) {
 }
 
 func extractAuthToken(r *http.Request) (string, bool) {
+	// Browsers cannot set headers when opening a WebSocket, so the token is passed as query parameter
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") && r.URL.Query().Has("token") {
+		return r.URL.Query().Get("token"), true
+	}
 	bearerToken := r.Header.Get("Authorization")
 	if !strings.HasPrefix(bearerToken, "Bearer: ") {
 		return "", false
@@ -0,0 +1,571 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/db"
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/bongofriend/bongo-notes/backend/lib/config"
+	"github.com/bongofriend/bongo-notes/backend/lib/ot"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownRevision = errors.New("unknown revision")
+	ErrCollabClosed    = errors.New("collaborative editing is shutting down")
+)
+
+const (
+	// collabHistoryLimit is the number of operations kept to transform operations of clients lagging behind
+	collabHistoryLimit = 1000
+	// collabSendBuffer is the number of messages queued for a client before it is disconnected as too slow
+	collabSendBuffer = 256
+	// checkpointTimeout is how long a checkpoint waits for its version to be stored
+	checkpointTimeout = 30 * time.Second
+)
+
+// CollabService runs the sessions of clients editing a note together. Operations of the clients are
+// transformed against each other, applied to the content of the session and periodically stored as
+// a new version of the note.
+type CollabService interface {
+	// Join connects a client to the session of a note, the session is started for the first client
+	Join(user models.User, notebookId uuid.UUID, noteId uuid.UUID) (*CollabConnection, error)
+	Start(context context.Context)
+	done() <-chan struct{}
+}
+
+type collabServiceImpl struct {
+	config         config.Config
+	notesRepo      db.NotesRepository
+	diffingService DiffingService
+	mu             sync.Mutex
+	rooms          map[uuid.UUID]*collabRoom
+	// closedRooms counts the sessions closed so far, so Join notices sessions closed while loading a note
+	closedRooms int
+	closed      bool
+	appContext  context.Context
+	doneCh      chan struct{}
+}
+
+// collabRoom is the session of a note. Its content is at revision, history holds the operations
+// that led to the last revisions.
+type collabRoom struct {
+	mu       sync.Mutex
+	noteId   uuid.UUID
+	content  []rune
+	revision int
+	history  []ot.Operation
+	// versionId is the version of the note the content of the session was last stored as or loaded from
+	versionId     uuid.UUID
+	savedRevision int
+	lastAuthor    uuid.UUID
+	maxSize       int64
+	checkpointing bool
+	clients       map[uuid.UUID]*CollabConnection
+}
+
+// CollabConnection is a client connected to the session of a note
+type CollabConnection struct {
+	id     uuid.UUID
+	user   models.User
+	room   *collabRoom
+	s      *collabServiceImpl
+	send   chan models.CollabMessage
+	cursor *models.Cursor
+	closed bool
+}
+
+// Messages returns the messages to send to the client. The channel is closed once the client is disconnected.
+func (c *CollabConnection) Messages() <-chan models.CollabMessage {
+	return c.send
+}
+
+// Handle processes a message received from the client
+func (c *CollabConnection) Handle(msg models.CollabMessage) {
+	r := c.room
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c.closed {
+		return
+	}
+	switch msg.Type {
+	case models.CollabOperation:
+		if msg.Operation == nil {
+			r.sendTo(c, errorMessage(errors.New("operation is missing")))
+			return
+		}
+		op, err := r.transform(*msg.Operation, msg.Revision)
+		if err == nil {
+			err = r.apply(op)
+		}
+		if err != nil {
+			r.sendTo(c, errorMessage(err))
+			return
+		}
+		r.lastAuthor = c.user.Id
+		r.sendTo(c, models.CollabMessage{Type: models.CollabAck, Revision: r.revision})
+		r.broadcast(models.CollabMessage{
+			Type:      models.CollabOperation,
+			Revision:  r.revision - 1,
+			ClientId:  &c.id,
+			Operation: &op,
+		}, c.id)
+	case models.CollabCursor:
+		if msg.Cursor == nil {
+			r.sendTo(c, errorMessage(errors.New("cursor is missing")))
+			return
+		}
+		cursor, err := r.transformCursor(*msg.Cursor, msg.Revision)
+		if err != nil {
+			r.sendTo(c, errorMessage(err))
+			return
+		}
+		c.cursor = &cursor
+		r.broadcast(models.CollabMessage{
+			Type:     models.CollabCursor,
+			Revision: r.revision,
+			ClientId: &c.id,
+			Cursor:   &cursor,
+		}, c.id)
+	default:
+		r.sendTo(c, errorMessage(fmt.Errorf("unsupported message type %q", msg.Type)))
+	}
+}
+
+// SendError reports a message of the client that could not be read
+func (c *CollabConnection) SendError(err error) {
+	c.room.mu.Lock()
+	defer c.room.mu.Unlock()
+	if !c.closed {
+		c.room.sendTo(c, errorMessage(err))
+	}
+}
+
+// Leave disconnects the client. The session of the note is stored and closed once the last client left.
+func (c *CollabConnection) Leave() {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	r := c.room
+	r.mu.Lock()
+	r.disconnect(c)
+	// Sessions closed by shutdown are stored already
+	empty := len(r.clients) == 0 && c.s.rooms[r.noteId] == r
+	r.mu.Unlock()
+	if empty {
+		go c.s.checkpoint(r)
+	}
+}
+
+func (c *CollabConnection) presence() models.CollabClient {
+	return models.CollabClient{
+		ClientId: c.id,
+		UserId:   c.user.Id,
+		Username: c.user.Username,
+		Cursor:   c.cursor,
+	}
+}
+
+func errorMessage(err error) models.CollabMessage {
+	return models.CollabMessage{
+		Type:    models.CollabError,
+		Message: err.Error(),
+	}
+}
+
+// Join implements CollabService.
+func (s *collabServiceImpl) Join(user models.User, notebookId uuid.UUID, noteId uuid.UUID) (*CollabConnection, error) {
+	isPartOf, err := s.notesRepo.IsNotePartOfNotebook(user.Id, notebookId, noteId)
+	if err != nil {
+		return nil, err
+	}
+	if !isPartOf {
+		return nil, fmt.Errorf("%w: user %s has not ownership of note %s", ErrNoteNotFound, user.Id, noteId)
+	}
+	var r *collabRoom
+	for r == nil {
+		s.mu.Lock()
+		closedRooms := s.closedRooms
+		_, running := s.rooms[noteId]
+		s.mu.Unlock()
+		var version models.NoteVersion
+		var loaded []byte
+		if !running {
+			// Loading waits for jobs of the note, so it must not block the sessions of other notes
+			if version, loaded, err = s.diffingService.GetCurrentContent(noteId); err != nil {
+				return nil, err
+			}
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return nil, ErrCollabClosed
+		}
+		r = s.rooms[noteId]
+		// The loaded content is discarded if a session started meanwhile, and loaded again if one was closed
+		// meanwhile, as it may have stored newer content
+		if r == nil && !running && s.closedRooms == closedRooms {
+			r = &collabRoom{
+				noteId:     noteId,
+				content:    []rune(string(loaded)),
+				versionId:  version.Id,
+				lastAuthor: user.Id,
+				maxSize:    s.config.Notes.MaxSize,
+				clients:    make(map[uuid.UUID]*CollabConnection),
+			}
+			s.rooms[noteId] = r
+		}
+		if r == nil {
+			s.mu.Unlock()
+		}
+	}
+	defer s.mu.Unlock()
+	c := &CollabConnection{
+		id:   uuid.New(),
+		user: user,
+		room: r,
+		s:    s,
+		send: make(chan models.CollabMessage, collabSendBuffer),
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clients := make([]models.CollabClient, 0, len(r.clients))
+	for _, other := range r.clients {
+		clients = append(clients, other.presence())
+	}
+	r.clients[c.id] = c
+	content := string(r.content)
+	r.sendTo(c, models.CollabMessage{
+		Type:      models.CollabInit,
+		Revision:  r.revision,
+		ClientId:  &c.id,
+		Content:   &content,
+		VersionId: &r.versionId,
+		Clients:   clients,
+	})
+	presence := c.presence()
+	r.broadcast(models.CollabMessage{
+		Type:     models.CollabJoin,
+		Revision: r.revision,
+		Client:   &presence,
+	}, c.id)
+	return c, nil
+}
+
+// Start implements CollabService. Sessions are stored every checkpoint interval. Once the context ends,
+// all clients are disconnected and the sessions are queued to be stored.
+func (s *collabServiceImpl) Start(context context.Context) {
+	s.appContext = context
+	go func() {
+		ticker := time.NewTicker(s.config.Collab.CheckpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				for _, r := range s.activeRooms() {
+					go s.checkpoint(r)
+				}
+			case <-context.Done():
+				s.shutdown()
+				close(s.doneCh)
+				return
+			}
+		}
+	}()
+}
+
+func (s *collabServiceImpl) done() <-chan struct{} {
+	return s.doneCh
+}
+
+func (s *collabServiceImpl) activeRooms() []*collabRoom {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rooms := make([]*collabRoom, 0, len(s.rooms))
+	for _, r := range s.rooms {
+		rooms = append(rooms, r)
+	}
+	return rooms
+}
+
+// shutdown disconnects all clients and queues the unsaved content of every session without waiting for it.
+// Jobs not processed before the diffing service stops are resumed on the next start.
+func (s *collabServiceImpl) shutdown() {
+	s.mu.Lock()
+	s.closed = true
+	rooms := s.rooms
+	s.rooms = make(map[uuid.UUID]*collabRoom)
+	s.mu.Unlock()
+	for _, r := range rooms {
+		r.mu.Lock()
+		for _, c := range r.clients {
+			r.disconnect(c)
+		}
+		if r.revision != r.savedRevision {
+			content := normalizeNote(string(r.content))
+			if _, err := s.diffingService.QueueContent(r.noteId, r.lastAuthor, []byte(content), &r.versionId, true); err != nil {
+				log.Printf("Could not store session of note %s: %s\n", r.noteId, err)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// checkpoint stores the content of a session as new version of the note if it changed since the last checkpoint.
+// Changes made to the note outside of the session are merged and sent to the clients as operation. The session is
+// closed once it is stored and its last client left.
+func (s *collabServiceImpl) checkpoint(r *collabRoom) {
+	for {
+		r.mu.Lock()
+		if r.checkpointing {
+			// The running checkpoint stores the changes and closes the session if needed
+			r.mu.Unlock()
+			return
+		}
+		if r.revision == r.savedRevision {
+			r.mu.Unlock()
+			s.closeIfEmpty(r)
+			return
+		}
+		r.checkpointing = true
+		content := string(r.content)
+		revision := r.revision
+		baseVersionId := r.versionId
+		author := r.lastAuthor
+		r.mu.Unlock()
+
+		result, err := s.store(r.noteId, author, content, baseVersionId)
+
+		r.mu.Lock()
+		r.checkpointing = false
+		if err != nil {
+			log.Printf("Could not store session of note %s: %s\n", r.noteId, err)
+			r.mu.Unlock()
+			s.closeIfEmpty(r)
+			return
+		}
+		stored := result.conflicts == 0
+		if stored {
+			r.versionId = result.versionId
+			r.savedRevision = revision
+		}
+		merged := false
+		if result.content != content {
+			op, err := r.transform(ot.FromDiff(content, result.content), revision)
+			if err == nil {
+				err = r.apply(op)
+			}
+			if err != nil {
+				log.Printf("Could not apply changes merged into session of note %s: %s\n", r.noteId, err)
+			} else {
+				merged = true
+				if stored && r.savedRevision == r.revision-1 {
+					r.savedRevision = r.revision
+				}
+				r.broadcast(models.CollabMessage{
+					Type:      models.CollabOperation,
+					Revision:  r.revision - 1,
+					Operation: &op,
+				}, uuid.Nil)
+			}
+		}
+		if stored {
+			r.broadcast(models.CollabMessage{
+				Type:      models.CollabCheckpoint,
+				Revision:  revision,
+				VersionId: &result.versionId,
+			}, uuid.Nil)
+		} else if merged {
+			// The session continues from the conflicting version, so the next checkpoint keeps its changes
+			r.versionId = result.versionId
+			r.broadcast(models.CollabMessage{
+				Type:      models.CollabConflict,
+				Revision:  r.revision,
+				VersionId: &result.versionId,
+				Message:   fmt.Sprintf("%d conflicts with changes made outside of the session are marked in the content", result.conflicts),
+			}, uuid.Nil)
+		}
+		// Changes made while the last client left would be lost otherwise
+		again := len(r.clients) == 0 && r.revision != r.savedRevision && (stored || merged)
+		r.mu.Unlock()
+		if !again {
+			s.closeIfEmpty(r)
+			return
+		}
+	}
+}
+
+// closeIfEmpty closes a session without clients. Sessions stay open until their final checkpoint is done, so clients
+// joining meanwhile continue the session instead of starting from content that lacks its changes.
+func (s *collabServiceImpl) closeIfEmpty(r *collabRoom) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.clients) == 0 && !r.checkpointing && s.rooms[r.noteId] == r {
+		delete(s.rooms, r.noteId)
+		s.closedRooms++
+	}
+}
+
+// checkpointResult is the outcome of storing the content of a session
+type checkpointResult struct {
+	versionId uuid.UUID
+	// content is the content the session continues with, including the changes made outside of the session
+	content string
+	// conflicts is the number of conflicts with changes made outside of the session. If there are any, nothing is
+	// stored, content has them marked and versionId is the version their changes come from.
+	conflicts int
+}
+
+// store queues content as new version based on the version the session was last stored as. Changes made outside
+// of the session are merged, if they conflict the content is not stored and the conflicts are marked instead.
+func (s *collabServiceImpl) store(noteId uuid.UUID, authorId uuid.UUID, content string, baseVersionId uuid.UUID) (checkpointResult, error) {
+	content = normalizeNote(content)
+	job, err := s.storeJob(noteId, authorId, content, &baseVersionId)
+	if err != nil {
+		return checkpointResult{}, err
+	}
+	if job.State == models.JobRejected {
+		current, result, err := s.diffingService.MergeContent(noteId, baseVersionId, []byte(content))
+		if err != nil {
+			return checkpointResult{}, err
+		}
+		log.Printf("Session of note %s conflicts with version %s: %s\n", noteId, current.Id, job.Error)
+		return checkpointResult{
+			versionId: current.Id,
+			content:   string(result.Content),
+			conflicts: len(result.Conflicts),
+		}, nil
+	}
+	if job.State != models.JobDone || job.VersionId == nil {
+		return checkpointResult{}, fmt.Errorf("%w: %s", ErrUpdateFailed, job.Error)
+	}
+	_, stored, err := s.diffingService.GetVersionContent(noteId, *job.VersionId)
+	if err != nil {
+		return checkpointResult{}, err
+	}
+	return checkpointResult{
+		versionId: *job.VersionId,
+		content:   string(stored),
+	}, nil
+}
+
+func (s *collabServiceImpl) storeJob(noteId uuid.UUID, authorId uuid.UUID, content string, baseVersionId *uuid.UUID) (models.DiffJob, error) {
+	job, err := s.diffingService.QueueContent(noteId, authorId, []byte(content), baseVersionId, baseVersionId != nil)
+	if err != nil {
+		return models.DiffJob{}, err
+	}
+	return s.diffingService.WaitForJob(s.appContext, job.Id, checkpointTimeout)
+}
+
+// transform transforms an operation of a client at the given revision against the operations applied since.
+// Callers must hold the lock of the room.
+func (r *collabRoom) transform(op ot.Operation, revision int) (ot.Operation, error) {
+	start := r.revision - len(r.history)
+	if revision < start || revision > r.revision {
+		return ot.Operation{}, fmt.Errorf("%w: %d, session is at revision %d", ErrUnknownRevision, revision, r.revision)
+	}
+	for _, applied := range r.history[revision-start:] {
+		var err error
+		if op, _, err = ot.Transform(op, applied); err != nil {
+			return ot.Operation{}, err
+		}
+	}
+	return op, nil
+}
+
+func (r *collabRoom) transformCursor(cursor models.Cursor, revision int) (models.Cursor, error) {
+	start := r.revision - len(r.history)
+	if revision < start || revision > r.revision {
+		return models.Cursor{}, fmt.Errorf("%w: %d, session is at revision %d", ErrUnknownRevision, revision, r.revision)
+	}
+	for _, applied := range r.history[revision-start:] {
+		cursor = models.Cursor{
+			Anchor: applied.TransformIndex(cursor.Anchor),
+			Head:   applied.TransformIndex(cursor.Head),
+		}
+	}
+	return cursor, nil
+}
+
+// apply applies an operation to the content as next revision and moves the cursors of all clients.
+// Callers must hold the lock of the room.
+func (r *collabRoom) apply(op ot.Operation) error {
+	content, err := op.Apply(r.content)
+	if err != nil {
+		return err
+	}
+	// Checkpoints have to pass the validation of notes, binary content is only detected once stored
+	if r.maxSize > 0 && int64(len(string(content))) > r.maxSize {
+		return fmt.Errorf("%w: at most %d bytes are allowed", ErrNoteTooLarge, r.maxSize)
+	}
+	if slices.Contains(content, 0) {
+		return fmt.Errorf("%w: content contains a NUL byte", ErrInvalidNoteContent)
+	}
+	r.content = content
+	r.revision++
+	r.history = append(r.history, op)
+	if len(r.history) > collabHistoryLimit {
+		r.history = r.history[len(r.history)-collabHistoryLimit:]
+	}
+	for _, c := range r.clients {
+		if c.cursor != nil {
+			c.cursor = &models.Cursor{
+				Anchor: op.TransformIndex(c.cursor.Anchor),
+				Head:   op.TransformIndex(c.cursor.Head),
+			}
+		}
+	}
+	return nil
+}
+
+// sendTo queues a message for a client and disconnects clients that do not keep up
+func (r *collabRoom) sendTo(c *CollabConnection, msg models.CollabMessage) {
+	select {
+	case c.send <- msg:
+	default:
+		log.Printf("Disconnecting client %s of note %s, too many messages queued\n", c.id, r.noteId)
+		r.disconnect(c)
+	}
+}
+
+// broadcast sends a message to all clients except the given one
+func (r *collabRoom) broadcast(msg models.CollabMessage, except uuid.UUID) {
+	for id, c := range r.clients {
+		if id != except {
+			r.sendTo(c, msg)
+		}
+	}
+}
+
+// disconnect removes a client from the room and tells the other clients about it
+func (r *collabRoom) disconnect(c *CollabConnection) {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.send)
+	delete(r.clients, c.id)
+	r.broadcast(models.CollabMessage{
+		Type:     models.CollabLeave,
+		Revision: r.revision,
+		ClientId: &c.id,
+	}, c.id)
+}
+
+func NewCollabService(c config.Config, notesRepo db.NotesRepository, diffingService DiffingService) CollabService {
+	return &collabServiceImpl{
+		config:         c,
+		notesRepo:      notesRepo,
+		diffingService: diffingService,
+		rooms:          make(map[uuid.UUID]*collabRoom),
+		appContext:     context.Background(),
+		doneCh:         make(chan struct{}),
+	}
+}
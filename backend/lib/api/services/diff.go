@@ -3,39 +3,75 @@ package services
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
-	"io"
 	"log"
+	"math"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/bongofriend/bongo-notes/backend/lib/api/db"
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
 	"github.com/bongofriend/bongo-notes/backend/lib/config"
+	"github.com/bongofriend/bongo-notes/backend/lib/diff"
 	"github.com/google/uuid"
 )
 
-const (
-	errorExitCode int = 2
+// CurrentVersion refers to the most recent content of a note when comparing versions
+const CurrentVersion = "current"
+
+var (
+	ErrVersionNotFound = errors.New("version not found")
+	ErrInvalidCursor   = errors.New("invalid cursor")
+	ErrUpdatePending   = errors.New("update is still pending")
+	// ErrPreconditionFailed is returned if a note is not at the version an update was based on
+	ErrPreconditionFailed = errors.New("note was changed")
+	// ErrMergeConflict is returned if an update conflicts with the changes made since the version it was based on
+	ErrMergeConflict = errors.New("merge conflict")
 )
 
 type DiffingService interface {
-	QueueFile(pathToNewContent string, noteId uuid.UUID)
+	// QueueContent persists a job storing content as new version of a note and queues it. With a base version,
+	// the job is rejected if the note is not at that version anymore when the job is processed. With merge,
+	// the changes made since the base version are merged into the content instead, the job is only rejected on conflicts.
+	QueueContent(noteId uuid.UUID, authorId uuid.UUID, content []byte, baseVersionId *uuid.UUID, merge bool) (models.DiffJob, error)
+	// MergeContent merges the changes made to a note since the base version into content.
+	// The most recent version the changes were taken from is returned with the result.
+	MergeContent(noteId uuid.UUID, baseVersionId uuid.UUID, content []byte) (models.NoteVersion, diff.MergeResult, error)
+	// WaitForJob waits until a job is done or dead. If the timeout or the context end first, ErrUpdatePending is returned.
+	WaitForJob(ctx context.Context, jobId uuid.UUID, timeout time.Duration) (models.DiffJob, error)
+	// GetCurrentContent returns the most recent content of a note together with its version
+	GetCurrentContent(noteId uuid.UUID) (models.NoteVersion, []byte, error)
+	// GetLatestVersion returns the most recent version of a note
+	GetLatestVersion(noteId uuid.UUID) (models.NoteVersion, error)
+	// GetVersion returns the metadata of a version without reconstructing its content
+	GetVersion(noteId uuid.UUID, versionId uuid.UUID) (models.NoteVersion, error)
+	// DeleteNote waits for a running job to finish and calls deleteFn. Queued jobs of the note are dropped
+	// once deleteFn removed them.
+	DeleteNote(noteId uuid.UUID, deleteFn func() error) error
+	// GetVersionContent reconstructs the content of a note as of the given version
+	GetVersionContent(noteId uuid.UUID, versionId uuid.UUID) (models.NoteVersion, []byte, error)
+	// ListVersions returns up to limit versions from newest to oldest, starting after the version given as cursor.
+	// The returned cursor is nil if there are no more versions.
+	ListVersions(noteId uuid.UUID, cursor *uuid.UUID, limit int) ([]models.NoteVersion, *uuid.UUID, error)
+	// CompareVersions diffs two versions of a note, nil refers to the most recent content
+	CompareVersions(noteId uuid.UUID, from *uuid.UUID, to *uuid.UUID, contextLines int) (models.NoteComparison, error)
 	Start(context context.Context)
 	done() <-chan struct{}
 }
 
-type diffingJob struct {
-	noteId         uuid.UUID
-	newContentPath string
-}
-
 type diffingServiceImpl struct {
-	jobCh       chan diffingJob
 	config      config.Config
 	diffingRepo db.DiffingRepository
+	jobsRepo    db.JobsRepository
+	indexer     noteIndexer
 	doneCh      chan struct{}
+	queue       *laneQueue
+	locks       *noteLocks
+	waiters     *jobWaiters
 }
 
 // Done implements DiffingService.
@@ -43,76 +79,615 @@ func (d diffingServiceImpl) done() <-chan struct{} {
 	return d.doneCh
 }
 
-// Start implements DiffinggService.
+// Start implements DiffinggService. Unfinished jobs are queued before Start returns, so they run before
+// any job queued afterwards for the same note.
 func (d diffingServiceImpl) Start(context context.Context) {
-	for {
-		defer func() {
-			d.doneCh <- struct{}{}
-			close(d.jobCh)
-			close(d.doneCh)
+	if err := d.resumeJobs(); err != nil {
+		log.Println(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < max(d.config.Jobs.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work()
 		}()
-		select {
-		case <-context.Done():
+	}
+	go func() {
+		<-context.Done()
+		d.queue.close()
+		wg.Wait()
+		close(d.doneCh)
+	}()
+}
+
+func (d diffingServiceImpl) work() {
+	for {
+		noteId, jobId, ok := d.queue.next()
+		if !ok {
 			return
-		case job := <-d.jobCh:
-			if err := d.processJob(job); err != nil {
-				log.Println(err)
-			}
 		}
+		retry, delay, err := d.handleJob(noteId, jobId)
+		if err != nil {
+			log.Println(err)
+		}
+		if retry {
+			// Later jobs of the note wait until the retry is done
+			time.AfterFunc(delay, func() {
+				d.queue.resume(noteId)
+			})
+			continue
+		}
+		d.queue.finish(noteId)
+	}
+}
+
+// resumeJobs queues the jobs left unfinished by a previous run and removes temporary content no job refers to
+func (d diffingServiceImpl) resumeJobs() error {
+	jobs, err := d.jobsRepo.GetUnfinishedJobs()
+	if err != nil {
+		return err
+	}
+	pending := make(map[string]bool)
+	for _, job := range jobs {
+		pending[job.Id.String()] = true
+		// Jobs accepted before the restart are queued regardless of the maximum depth
+		d.queue.reserve(false)
+		d.queue.add(job.NoteId, job.Id)
+	}
+	if len(jobs) > 0 {
+		log.Printf("Resuming %d unfinished jobs\n", len(jobs))
+	}
+	entries, err := os.ReadDir(d.tempPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if pending[e.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(d.tempPath(), e.Name())); err != nil {
+			log.Println(err)
+		}
+	}
+	return nil
+}
+
+// handleJob runs a job and reports whether and after which delay it has to be retried
+func (d diffingServiceImpl) handleJob(noteId uuid.UUID, jobId uuid.UUID) (bool, time.Duration, error) {
+	unlock := d.locks.lock(noteId)
+	defer unlock()
+	job, err := d.jobsRepo.GetJob(jobId)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Dropping job %s of deleted note\n", jobId)
+		d.waiters.finished(jobId)
+		return false, 0, removeIfExists(d.jobContentPath(jobId))
+	}
+	if err != nil {
+		return true, d.config.Jobs.RetryDelay, err
+	}
+	if job.Finished() {
+		return false, 0, nil
+	}
+	// Jobs queued while their note was deleted are dropped like the ones deleted with it
+	dropped, err := d.jobsRepo.DeleteJobOfDeletedNote(job.Id)
+	if err != nil {
+		return true, d.config.Jobs.RetryDelay, err
+	}
+	if dropped {
+		log.Printf("Dropping job %s of deleted note %s\n", job.Id, job.NoteId)
+		d.waiters.finished(job.Id)
+		return false, 0, removeIfExists(d.jobContentPath(job.Id))
+	}
+	// Failed jobs resumed after a restart keep their backoff
+	if job.State == models.JobFailed && time.Now().Before(job.NextAttemptAt) {
+		return true, time.Until(job.NextAttemptAt), nil
+	}
+	if err := d.jobsRepo.MarkRunning(job.Id); err != nil {
+		return true, d.config.Jobs.RetryDelay, err
+	}
+	job.Attempts++
+	versionId, jobErr := d.processJob(job)
+	if jobErr == nil {
+		if err := d.jobsRepo.MarkDone(job.Id, versionId); err != nil {
+			return true, d.config.Jobs.RetryDelay, err
+		}
+		d.waiters.finished(job.Id)
+		return false, 0, nil
+	}
+	if errors.Is(jobErr, ErrPreconditionFailed) || errors.Is(jobErr, ErrMergeConflict) {
+		if err := removeIfExists(d.jobContentPath(job.Id)); err != nil {
+			log.Println(err)
+		}
+		if err := d.jobsRepo.MarkRejected(job.Id, jobErr.Error()); err != nil {
+			return true, d.config.Jobs.RetryDelay, err
+		}
+		d.waiters.finished(job.Id)
+		return false, 0, nil
+	}
+	if job.Attempts >= d.config.Jobs.MaxAttempts {
+		if err := removeIfExists(d.jobContentPath(job.Id)); err != nil {
+			log.Println(err)
+		}
+		if err := d.jobsRepo.MarkDead(job.Id, jobErr.Error()); err != nil {
+			return true, d.config.Jobs.RetryDelay, err
+		}
+		d.waiters.finished(job.Id)
+		return false, 0, fmt.Errorf("giving up on job %s after %d attempts: %w", job.Id, job.Attempts, jobErr)
+	}
+	delay := d.config.Jobs.RetryDelay * time.Duration(1<<(job.Attempts-1))
+	if err := d.jobsRepo.MarkFailed(job.Id, jobErr.Error(), time.Now().Add(delay)); err != nil {
+		return true, delay, err
+	}
+	return true, delay, fmt.Errorf("job %s failed, retrying in %s: %w", job.Id, delay, jobErr)
+}
+
+// waitedJob returns a job waited for, jobs dropped with their note are reported as ErrNoteNotFound
+func (d diffingServiceImpl) waitedJob(jobId uuid.UUID) (models.DiffJob, error) {
+	job, err := d.jobsRepo.GetJob(jobId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.DiffJob{}, fmt.Errorf("%w: job %s was dropped with its note", ErrNoteNotFound, jobId)
+	}
+	return job, err
+}
+
+// WaitForJob implements DiffingService.
+func (d diffingServiceImpl) WaitForJob(ctx context.Context, jobId uuid.UUID, timeout time.Duration) (models.DiffJob, error) {
+	finishedCh := d.waiters.wait(jobId)
+	defer d.waiters.cancel(jobId, finishedCh)
+	// The job may have finished before waiting started
+	job, err := d.waitedJob(jobId)
+	if err != nil {
+		return models.DiffJob{}, err
+	}
+	if job.Finished() {
+		return job, nil
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-finishedCh:
+		return d.waitedJob(jobId)
+	case <-timer.C:
+		return job, ErrUpdatePending
+	case <-ctx.Done():
+		return job, ErrUpdatePending
+	}
+}
+
+// GetCurrentContent implements DiffingService.
+func (d diffingServiceImpl) GetCurrentContent(noteId uuid.UUID) (models.NoteVersion, []byte, error) {
+	unlock := d.locks.lock(noteId)
+	defer unlock()
+	version, err := d.latestVersion(noteId)
+	if err != nil {
+		return models.NoteVersion{}, nil, err
+	}
+	content, err := d.currentContent(noteId)
+	if err != nil {
+		return models.NoteVersion{}, nil, err
+	}
+	return version, content, nil
+}
+
+// GetLatestVersion implements DiffingService.
+func (d diffingServiceImpl) GetLatestVersion(noteId uuid.UUID) (models.NoteVersion, error) {
+	return d.latestVersion(noteId)
+}
+
+func (d diffingServiceImpl) latestVersion(noteId uuid.UUID) (models.NoteVersion, error) {
+	versions, err := d.diffingRepo.GetVersions(noteId, math.MaxInt, 1)
+	if err != nil {
+		return models.NoteVersion{}, err
+	}
+	if len(versions) == 0 {
+		return models.NoteVersion{}, fmt.Errorf("%w: no version found for note %s", ErrVersionNotFound, noteId)
+	}
+	return versions[0], nil
+}
+
+// GetVersion implements DiffingService.
+func (d diffingServiceImpl) GetVersion(noteId uuid.UUID, versionId uuid.UUID) (models.NoteVersion, error) {
+	version, err := d.diffingRepo.GetVersion(noteId, versionId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NoteVersion{}, fmt.Errorf("%w: %s for note %s", ErrVersionNotFound, versionId, noteId)
+	}
+	return version, err
+}
+
+// DeleteNote implements DiffingService.
+func (d diffingServiceImpl) DeleteNote(noteId uuid.UUID, deleteFn func() error) error {
+	unlock := d.locks.lock(noteId)
+	defer unlock()
+	return deleteFn()
+}
+
+// processJob stores the content of a job as new version. The version gets the id of the job, so a job interrupted
+// after storing its version is only completed when it is run again.
+func (d diffingServiceImpl) processJob(job models.DiffJob) (uuid.UUID, error) {
+	newContentPath := d.jobContentPath(job.Id)
+	_, err := d.diffingRepo.GetVersion(job.NoteId, job.Id)
+	if err == nil {
+		if _, err := os.Stat(newContentPath); errors.Is(err, os.ErrNotExist) {
+			return job.Id, nil
+		}
+		newContent, err := os.ReadFile(newContentPath)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if err := d.updateNoteContent(job.NoteId, newContentPath); err != nil {
+			return uuid.Nil, err
+		}
+		d.indexContent(job.NoteId, newContent)
+		return job.Id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, err
+	}
+	previousVersion, err := d.latestVersion(job.NoteId)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	// Checked while holding the lock of the note, so no other job can change the note in between
+	if job.BaseVersionId != nil && *job.BaseVersionId != previousVersion.Id {
+		if !job.Merge {
+			return uuid.Nil, fmt.Errorf("%w: update was based on version %s but note is at version %s", ErrPreconditionFailed, *job.BaseVersionId, previousVersion.Id)
+		}
+		if err := d.mergeJobContent(job); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	storedDiff, err := d.generatedDiff(newContentPath, job.NoteId, job.Id, previousVersion.Id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	newContent, err := os.ReadFile(newContentPath)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	stats, err := versionStats(newContent, storedDiff)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := d.diffingRepo.AddDiff(job.NoteId, job.Id, job.AuthorId, stats); err != nil {
+		if removeErr := os.Remove(d.diffFilePath(job.NoteId, job.Id)); removeErr != nil {
+			log.Println(removeErr)
+		}
+		return uuid.Nil, err
+	}
+	// A failed snapshot only makes reconstruction slower, the new version is already stored
+	if err := d.snapshotIfNeeded(job.NoteId, previousVersion); err != nil {
+		log.Printf("Could not store snapshot of version %s: %s\n", previousVersion.Id, err)
+	}
+	if err := d.updateNoteContent(job.NoteId, newContentPath); err != nil {
+		return uuid.Nil, err
 	}
+	d.indexContent(job.NoteId, newContent)
+	return job.Id, nil
 }
 
-func (d diffingServiceImpl) processJob(job diffingJob) error {
-	diffId, err := d.generatedDiff(job.newContentPath, job.noteId)
+// indexContent updates the search index and the links with the content of a new version. A failure only leaves
+// them outdated until the next version or a reindex, the version is already stored.
+func (d diffingServiceImpl) indexContent(noteId uuid.UUID, content []byte) {
+	if err := d.indexer.index(noteId, content); err != nil {
+		log.Printf("Could not index note %s: %s\n", noteId, err)
+	}
+}
+
+// mergeJobContent replaces the content of a job with its merge with the changes made since its base version.
+// Callers must hold the lock of the note.
+func (d diffingServiceImpl) mergeJobContent(job models.DiffJob) error {
+	contentPath := d.jobContentPath(job.Id)
+	content, err := os.ReadFile(contentPath)
 	if err != nil {
 		return err
 	}
-	if err := d.diffingRepo.AddDiff(job.noteId, diffId); err != nil {
+	current, result, err := d.merge(job.NoteId, *job.BaseVersionId, content)
+	if err != nil {
 		return err
 	}
-	if err := d.updateNoteContent(job.noteId, job.newContentPath); err != nil {
+	if !result.Clean() {
+		return fmt.Errorf("%w: %d conflicts between version %s and update based on version %s", ErrMergeConflict, len(result.Conflicts), current.Id, *job.BaseVersionId)
+	}
+	// Replaced atomically, so a retried job never reads partially merged content
+	mergedPath := contentPath + ".merged"
+	if err := os.WriteFile(mergedPath, result.Content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(mergedPath, contentPath)
+}
+
+// MergeContent implements DiffingService.
+func (d diffingServiceImpl) MergeContent(noteId uuid.UUID, baseVersionId uuid.UUID, content []byte) (models.NoteVersion, diff.MergeResult, error) {
+	unlock := d.locks.lock(noteId)
+	defer unlock()
+	return d.merge(noteId, baseVersionId, content)
+}
+
+func (d diffingServiceImpl) merge(noteId uuid.UUID, baseVersionId uuid.UUID, content []byte) (models.NoteVersion, diff.MergeResult, error) {
+	_, baseContent, err := d.versionContent(noteId, baseVersionId)
+	if err != nil {
+		return models.NoteVersion{}, diff.MergeResult{}, err
+	}
+	current, err := d.latestVersion(noteId)
+	if err != nil {
+		return models.NoteVersion{}, diff.MergeResult{}, err
+	}
+	currentContent, err := d.currentContent(noteId)
+	if err != nil {
+		return models.NoteVersion{}, diff.MergeResult{}, err
+	}
+	return current, diff.Merge(baseContent, currentContent, content, current.Id.String(), "update"), nil
+}
+
+// snapshotIfNeeded stores the content of the previous version in full once the diffs needed to reconstruct
+// it from the newest snapshot or the most recent content exceed the configured number of versions or bytes.
+// It has to be called before the most recent content is replaced.
+func (d diffingServiceImpl) snapshotIfNeeded(noteId uuid.UUID, previousVersion models.NoteVersion) error {
+	snapshotVersion := 0
+	latestSnapshot, err := d.diffingRepo.GetLatestSnapshot(noteId)
+	if err == nil {
+		snapshotVersion = latestSnapshot.Version
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	chain, err := d.diffingRepo.GetVersionsBetween(noteId, snapshotVersion, math.MaxInt)
+	if err != nil {
+		return err
+	}
+	var chainSize int64
+	for _, v := range chain {
+		chainSize += v.DiffSize
+	}
+	interval, maxDiffBytes := d.config.Snapshots.Interval, d.config.Snapshots.MaxDiffBytes
+	if (interval <= 0 || previousVersion.Version-snapshotVersion < interval) && (maxDiffBytes <= 0 || chainSize < maxDiffBytes) {
+		return nil
+	}
+	content, err := d.currentContent(noteId)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(d.config.NotesFolderPath, noteId.String(), "snapshots"), 0755); err != nil {
+		return err
+	}
+	snapshotPath := d.snapshotFilePath(noteId, previousVersion.Id)
+	if err := os.WriteFile(snapshotPath, content, 0644); err != nil {
+		return err
+	}
+	snapshot := models.NoteSnapshot{
+		VersionId: previousVersion.Id,
+		Version:   previousVersion.Version,
+		Size:      int64(len(content)),
+	}
+	if err := d.diffingRepo.AddSnapshot(noteId, snapshot); err != nil {
+		if removeErr := os.Remove(snapshotPath); removeErr != nil {
+			log.Println(removeErr)
+		}
 		return err
 	}
 	return nil
 }
 
-func (d diffingServiceImpl) generatedDiff(newContentPath string, noteId uuid.UUID) (uuid.UUID, error) {
+func (d diffingServiceImpl) snapshotFilePath(noteId uuid.UUID, versionId uuid.UUID) string {
+	return filepath.Join(d.config.NotesFolderPath, noteId.String(), "snapshots", versionId.String())
+}
+
+// versionStats computes the statistics of a version from its content and the reverse diff to its predecessor
+func versionStats(content []byte, storedDiff []byte) (models.VersionStats, error) {
+	stats := models.VersionStats{
+		Size:      int64(len(content)),
+		LineCount: countLines(content),
+		DiffSize:  int64(len(storedDiff)),
+	}
+	hunks, err := diff.StoredHunks(storedDiff)
+	if err != nil {
+		return models.VersionStats{}, err
+	}
+	for _, h := range hunks {
+		for _, l := range h.Lines {
+			// The diff describes how to get from the new content back to the previous one
+			switch l.Type {
+			case diff.Removed:
+				stats.LinesAdded++
+			case diff.Added:
+				stats.LinesRemoved++
+			}
+		}
+	}
+	return stats, nil
+}
+
+func countLines(content []byte) int {
+	lines := bytes.Count(content, []byte("\n"))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
+
+func (d diffingServiceImpl) generatedDiff(newContentPath string, noteId uuid.UUID, diffId uuid.UUID, previousVersionId uuid.UUID) ([]byte, error) {
 	if _, err := os.Stat(newContentPath); err != nil {
-		return uuid.Nil, fmt.Errorf("no new content found in %s for note %s", newContentPath, noteId.String())
+		return nil, fmt.Errorf("no new content found in %s for note %s", newContentPath, noteId.String())
 	}
 	notesPath := filepath.Join(d.config.NotesFolderPath, noteId.String())
 	if _, err := os.Stat(notesPath); err != nil {
-		return uuid.Nil, fmt.Errorf("no note in path %s for note with ID %s", notesPath, noteId.String())
+		return nil, fmt.Errorf("no note in path %s for note with ID %s", notesPath, noteId.String())
 
 	}
 	currentNotePath := filepath.Join(notesPath, "recent")
 	if _, err := os.Stat(currentNotePath); err != nil {
-		return uuid.Nil, fmt.Errorf("current state for note %s could not be found in %s", noteId.String(), notesPath)
+		return nil, fmt.Errorf("current state for note %s could not be found in %s", noteId.String(), notesPath)
+	}
+	newContent, err := os.ReadFile(newContentPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not diff: %w", err)
+	}
+	currentContent, err := os.ReadFile(currentNotePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not diff: %w", err)
 	}
-	cmd := exec.Command("diff", newContentPath, currentNotePath)
-	output, _ := cmd.Output()
-	exitCode := cmd.ProcessState.ExitCode()
-	if exitCode == errorExitCode {
-		diffError := errors.New(string(output))
-		return uuid.Nil, fmt.Errorf("could not diff: %w", diffError)
+	output, err := diff.Encode(diffId.String(), previousVersionId.String(), newContent, currentContent)
+	if err != nil {
+		return nil, fmt.Errorf("could not diff: %w", err)
 	}
 
 	diffPath := filepath.Join(notesPath, "diffs")
 	if err := os.MkdirAll(diffPath, 0755); err != nil {
-		return uuid.Nil, fmt.Errorf("could not diff: %w", err)
+		return nil, fmt.Errorf("could not diff: %w", err)
+	}
+	if err := os.WriteFile(d.diffFilePath(noteId, diffId), output, 0644); err != nil {
+		return nil, fmt.Errorf("could not diff: %w", err)
+	}
+	return output, nil
+}
+
+func (d diffingServiceImpl) tempPath() string {
+	return filepath.Join(d.config.NotesFolderPath, "temp")
+}
+
+// jobContentPath is the location of the content a job stores as new version
+func (d diffingServiceImpl) jobContentPath(jobId uuid.UUID) string {
+	return filepath.Join(d.tempPath(), jobId.String())
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (d diffingServiceImpl) diffFilePath(noteId uuid.UUID, diffId uuid.UUID) string {
+	return filepath.Join(d.config.NotesFolderPath, noteId.String(), "diffs", fmt.Sprintf("%s.diff", diffId))
+}
+
+// GetVersionContent implements DiffingService.
+func (d diffingServiceImpl) GetVersionContent(noteId uuid.UUID, versionId uuid.UUID) (models.NoteVersion, []byte, error) {
+	unlock := d.locks.lock(noteId)
+	defer unlock()
+	return d.versionContent(noteId, versionId)
+}
+
+// versionContent reconstructs a version starting from the nearest snapshot or the most recent content by
+// applying the stored reverse diffs of the newer versions from newest to oldest. Callers must hold the lock of the note.
+func (d diffingServiceImpl) versionContent(noteId uuid.UUID, versionId uuid.UUID) (models.NoteVersion, []byte, error) {
+	version, err := d.diffingRepo.GetVersion(noteId, versionId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NoteVersion{}, nil, fmt.Errorf("%w: %s for note %s", ErrVersionNotFound, versionId, noteId)
 	}
-	diffId := uuid.New()
-	diffFilePath := filepath.Join(diffPath, fmt.Sprintf("%s.diff", diffId))
-	diffFile, err := os.Create(diffFilePath)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("could not diff: %w", err)
+		return models.NoteVersion{}, nil, err
 	}
-	defer diffFile.Close()
-	buf := make([]byte, 1024*1024)
-	if _, err := io.CopyBuffer(diffFile, bytes.NewReader(output), buf); err != nil {
-		return uuid.Nil, err
+	content, untilVersion, err := d.reconstructionStart(noteId, version.Version)
+	if err != nil {
+		return models.NoteVersion{}, nil, err
+	}
+	newerVersions, err := d.diffingRepo.GetVersionsBetween(noteId, version.Version, untilVersion)
+	if err != nil {
+		return models.NoteVersion{}, nil, err
+	}
+	for _, v := range newerVersions {
+		content, err = applyPatch(content, d.diffFilePath(noteId, v.Id))
+		if err != nil {
+			return models.NoteVersion{}, nil, fmt.Errorf("could not apply diff of version %s: %w", v.Id, err)
+		}
+	}
+	return version, content, nil
+}
+
+// reconstructionStart returns the content of the oldest snapshot not older than version together with the
+// version of the snapshot. Without such a snapshot the most recent content is used.
+func (d diffingServiceImpl) reconstructionStart(noteId uuid.UUID, version int) ([]byte, int, error) {
+	snapshot, err := d.diffingRepo.GetNearestSnapshot(noteId, version)
+	if err == nil {
+		content, err := os.ReadFile(d.snapshotFilePath(noteId, snapshot.VersionId))
+		if err == nil {
+			return content, snapshot.Version, nil
+		}
+		log.Printf("Could not read snapshot of version %s, falling back to most recent content: %s\n", snapshot.VersionId, err)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, err
+	}
+	content, err := d.currentContent(noteId)
+	if err != nil {
+		return nil, 0, err
+	}
+	return content, math.MaxInt, nil
+}
+
+func (d diffingServiceImpl) currentContent(noteId uuid.UUID) ([]byte, error) {
+	content, err := os.ReadFile(filepath.Join(d.config.NotesFolderPath, noteId.String(), "recent"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read note: %w", err)
 	}
-	return diffId, nil
+	return content, nil
+}
+
+// CompareVersions implements DiffingService.
+func (d diffingServiceImpl) CompareVersions(noteId uuid.UUID, from *uuid.UUID, to *uuid.UUID, contextLines int) (models.NoteComparison, error) {
+	unlock := d.locks.lock(noteId)
+	defer unlock()
+	fromLabel, fromContent, err := d.versionOrCurrentContent(noteId, from)
+	if err != nil {
+		return models.NoteComparison{}, err
+	}
+	toLabel, toContent, err := d.versionOrCurrentContent(noteId, to)
+	if err != nil {
+		return models.NoteComparison{}, err
+	}
+	hunks := diff.UnifiedHunks(fromContent, toContent, contextLines)
+	return models.NoteComparison{
+		From:    fromLabel,
+		To:      toLabel,
+		Unified: diff.FormatUnified(fromLabel, toLabel, hunks),
+		Hunks:   hunks,
+	}, nil
+}
+
+func (d diffingServiceImpl) versionOrCurrentContent(noteId uuid.UUID, versionId *uuid.UUID) (string, []byte, error) {
+	if versionId == nil {
+		content, err := d.currentContent(noteId)
+		return CurrentVersion, content, err
+	}
+	_, content, err := d.versionContent(noteId, *versionId)
+	return versionId.String(), content, err
+}
+
+// ListVersions implements DiffingService.
+func (d diffingServiceImpl) ListVersions(noteId uuid.UUID, cursor *uuid.UUID, limit int) ([]models.NoteVersion, *uuid.UUID, error) {
+	beforeVersion := math.MaxInt
+	if cursor != nil {
+		cursorVersion, err := d.diffingRepo.GetVersion(noteId, *cursor)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, fmt.Errorf("%w: %s is not a version of note %s", ErrInvalidCursor, *cursor, noteId)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		beforeVersion = cursorVersion.Version
+	}
+	versions, err := d.diffingRepo.GetVersions(noteId, beforeVersion, limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(versions) <= limit {
+		return versions, nil, nil
+	}
+	versions = versions[:limit]
+	nextCursor := versions[limit-1].Id
+	return versions, &nextCursor, nil
+}
+
+// applyPatch applies the reverse diff stored at diffPath to content
+func applyPatch(content []byte, diffPath string) ([]byte, error) {
+	storedDiff, err := os.ReadFile(diffPath)
+	if err != nil {
+		return nil, err
+	}
+	return diff.Apply(content, storedDiff)
 }
 
 func (d diffingServiceImpl) updateNoteContent(noteId uuid.UUID, newContentPath string) error {
@@ -132,22 +707,60 @@ func (d diffingServiceImpl) updateNoteContent(noteId uuid.UUID, newContentPath s
 	return nil
 }
 
-// QueueFile implements DiffinggService.
-func (d diffingServiceImpl) QueueFile(pathToNewContent string, noteId uuid.UUID) {
-	job := diffingJob{
-		newContentPath: pathToNewContent,
-		noteId:         noteId,
+// QueueContent implements DiffingService. It fails with ErrQueueFull if the maximum number of queued jobs is reached.
+func (d diffingServiceImpl) QueueContent(noteId uuid.UUID, authorId uuid.UUID, content []byte, baseVersionId *uuid.UUID, merge bool) (models.DiffJob, error) {
+	if err := d.queue.reserve(true); err != nil {
+		return models.DiffJob{}, err
 	}
-	go func() {
-		d.jobCh <- job
-	}()
+	job, err := d.persistJob(noteId, authorId, content, baseVersionId, merge)
+	if err != nil {
+		d.queue.release()
+		return models.DiffJob{}, err
+	}
+	d.queue.add(noteId, job.Id)
+	return job, nil
+}
+
+func (d diffingServiceImpl) persistJob(noteId uuid.UUID, authorId uuid.UUID, content []byte, baseVersionId *uuid.UUID, merge bool) (models.DiffJob, error) {
+	job := models.DiffJob{
+		Id:            uuid.New(),
+		NoteId:        noteId,
+		AuthorId:      authorId,
+		BaseVersionId: baseVersionId,
+		Merge:         merge,
+	}
+	if err := os.MkdirAll(d.tempPath(), 0755); err != nil {
+		return models.DiffJob{}, err
+	}
+	contentPath := d.jobContentPath(job.Id)
+	if err := os.WriteFile(contentPath, content, 0644); err != nil {
+		return models.DiffJob{}, err
+	}
+	if err := d.jobsRepo.AddJob(job); err != nil {
+		if removeErr := os.Remove(contentPath); removeErr != nil {
+			log.Println(removeErr)
+		}
+		return models.DiffJob{}, err
+	}
+	return d.jobsRepo.GetJob(job.Id)
 }
 
-func NewDiffingService(config config.Config, diffingRepo db.DiffingRepository) DiffingService {
+func NewDiffingService(config config.Config, diffingRepo db.DiffingRepository, jobsRepo db.JobsRepository, searchRepo db.SearchRepository, linksRepo db.LinksRepository) DiffingService {
 	return diffingServiceImpl{
 		config:      config,
-		jobCh:       make(chan diffingJob, 10),
 		diffingRepo: diffingRepo,
-		doneCh:      make(chan struct{}),
+		jobsRepo:    jobsRepo,
+		indexer: noteIndexer{
+			searchRepo: searchRepo,
+			linksRepo:  linksRepo,
+		},
+		doneCh: make(chan struct{}),
+		queue:  newLaneQueue(config.Jobs.MaxQueueDepth),
+		locks: &noteLocks{
+			locks: make(map[uuid.UUID]*noteLock),
+		},
+		waiters: &jobWaiters{
+			waiters: make(map[uuid.UUID][]chan struct{}),
+		},
 	}
 }
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+var ErrQueueFull = errors.New("too many queued updates")
+
+// laneQueue keeps a FIFO lane of jobs per note. A lane is handed to one worker at a time, so jobs of
+// the same note run strictly in order while different notes are processed in parallel.
+type laneQueue struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	lanes    map[uuid.UUID][]uuid.UUID
+	ready    []uuid.UUID
+	depth    int
+	maxDepth int
+	closed   bool
+}
+
+func newLaneQueue(maxDepth int) *laneQueue {
+	q := &laneQueue{
+		lanes:    make(map[uuid.UUID][]uuid.UUID),
+		maxDepth: maxDepth,
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// reserve claims room for a job and fails with ErrQueueFull if the maximum depth is reached.
+// Without a limit, room is claimed regardless of the depth.
+func (q *laneQueue) reserve(limit bool) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if limit && q.maxDepth > 0 && q.depth >= q.maxDepth {
+		return ErrQueueFull
+	}
+	q.depth++
+	return nil
+}
+
+// release gives back room reserved for a job that was not added
+func (q *laneQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.depth--
+}
+
+// add appends a job to the lane of its note. Room for the job has to be reserved before.
+func (q *laneQueue) add(noteId uuid.UUID, jobId uuid.UUID) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	lane, exists := q.lanes[noteId]
+	q.lanes[noteId] = append(lane, jobId)
+	// A lane that already exists is either ready, being worked on or waiting for a retry
+	if !exists {
+		q.ready = append(q.ready, noteId)
+		q.cond.Signal()
+	}
+}
+
+// next blocks until a lane is ready and returns its note together with the job at the head of the lane.
+// It returns false once the queue is closed.
+func (q *laneQueue) next() (uuid.UUID, uuid.UUID, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.ready) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return uuid.Nil, uuid.Nil, false
+	}
+	noteId := q.ready[0]
+	q.ready = q.ready[1:]
+	return noteId, q.lanes[noteId][0], true
+}
+
+// finish removes the head of a lane after its job was handled and makes the lane ready again if
+// further jobs are waiting in it
+func (q *laneQueue) finish(noteId uuid.UUID) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.depth--
+	lane := q.lanes[noteId][1:]
+	if len(lane) == 0 {
+		delete(q.lanes, noteId)
+		return
+	}
+	q.lanes[noteId] = lane
+	q.ready = append(q.ready, noteId)
+	q.cond.Signal()
+}
+
+// resume makes a lane ready again whose head job waited for a retry
+func (q *laneQueue) resume(noteId uuid.UUID) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.lanes[noteId]; !ok || q.closed {
+		return
+	}
+	q.ready = append(q.ready, noteId)
+	q.cond.Signal()
+}
+
+// close wakes up all waiting workers and makes next return false
+func (q *laneQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// noteLocks serializes the processing of jobs with reading and deleting the same note
+type noteLocks struct {
+	mu    sync.Mutex
+	locks map[uuid.UUID]*noteLock
+}
+
+type noteLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks the note and returns the function to unlock it again
+func (n *noteLocks) lock(noteId uuid.UUID) func() {
+	n.mu.Lock()
+	l, ok := n.locks[noteId]
+	if !ok {
+		l = &noteLock{}
+		n.locks[noteId] = l
+	}
+	l.refs++
+	n.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(n.locks, noteId)
+		}
+	}
+}
+
+// jobWaiters notifies callers waiting for jobs to finish
+type jobWaiters struct {
+	mu      sync.Mutex
+	waiters map[uuid.UUID][]chan struct{}
+}
+
+// wait returns a channel that is closed once the job finished
+func (j *jobWaiters) wait(jobId uuid.UUID) chan struct{} {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	ch := make(chan struct{})
+	j.waiters[jobId] = append(j.waiters[jobId], ch)
+	return ch
+}
+
+// cancel stops waiting for a job
+func (j *jobWaiters) cancel(jobId uuid.UUID, ch chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	waiters := j.waiters[jobId]
+	for i, w := range waiters {
+		if w == ch {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(j.waiters, jobId)
+		return
+	}
+	j.waiters[jobId] = waiters
+}
+
+func (j *jobWaiters) finished(jobId uuid.UUID) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, ch := range j.waiters[jobId] {
+		close(ch)
+	}
+	delete(j.waiters, jobId)
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/db"
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/google/uuid"
+)
+
+var ErrJobNotFound = errors.New("job not found")
+
+type JobsService interface {
+	// GetJob returns a job queued by the user
+	GetJob(user models.User, jobId uuid.UUID) (models.DiffJob, error)
+}
+
+type jobsServiceImpl struct {
+	jobsRepo db.JobsRepository
+}
+
+func NewJobsService(jobsRepo db.JobsRepository) JobsService {
+	return jobsServiceImpl{
+		jobsRepo: jobsRepo,
+	}
+}
+
+// GetJob implements JobsService.
+func (j jobsServiceImpl) GetJob(user models.User, jobId uuid.UUID) (models.DiffJob, error) {
+	job, err := j.jobsRepo.GetJob(jobId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.DiffJob{}, fmt.Errorf("%w: %s", ErrJobNotFound, jobId)
+	}
+	if err != nil {
+		return models.DiffJob{}, err
+	}
+	if job.AuthorId != user.Id {
+		return models.DiffJob{}, fmt.Errorf("%w: job %s was not queued by user %s", ErrJobNotFound, jobId, user.Id)
+	}
+	return job, nil
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/db"
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/bongofriend/bongo-notes/backend/lib/markdown"
+	"github.com/google/uuid"
+)
+
+type LinksService interface {
+	// GetLinks returns the wiki links of a note in order of appearance, unresolved links have no note
+	GetLinks(user models.User, notebookId uuid.UUID, noteId uuid.UUID) ([]models.NoteLink, error)
+	// GetBacklinks returns the notes linking to a note
+	GetBacklinks(user models.User, notebookId uuid.UUID, noteId uuid.UUID) ([]models.Note, error)
+}
+
+type linksServiceImpl struct {
+	linksRepo db.LinksRepository
+	notesRepo db.NotesRepository
+}
+
+func NewLinksService(linksRepo db.LinksRepository, notesRepo db.NotesRepository) LinksService {
+	return linksServiceImpl{
+		linksRepo: linksRepo,
+		notesRepo: notesRepo,
+	}
+}
+
+// GetLinks implements LinksService.
+func (l linksServiceImpl) GetLinks(user models.User, notebookId uuid.UUID, noteId uuid.UUID) ([]models.NoteLink, error) {
+	if err := l.checkNoteOwnership(user, notebookId, noteId); err != nil {
+		return nil, err
+	}
+	return l.linksRepo.GetLinks(user.Id, noteId)
+}
+
+// GetBacklinks implements LinksService.
+func (l linksServiceImpl) GetBacklinks(user models.User, notebookId uuid.UUID, noteId uuid.UUID) ([]models.Note, error) {
+	if err := l.checkNoteOwnership(user, notebookId, noteId); err != nil {
+		return nil, err
+	}
+	return l.linksRepo.GetBacklinks(user.Id, noteId)
+}
+
+func (l linksServiceImpl) checkNoteOwnership(user models.User, notebookId uuid.UUID, noteId uuid.UUID) error {
+	isPartOf, err := l.notesRepo.IsNotePartOfNotebook(user.Id, notebookId, noteId)
+	if err != nil {
+		return err
+	}
+	if !isPartOf {
+		return fmt.Errorf("%w: user %s has not ownership of note %s", ErrNoteNotFound, user.Id, noteId)
+	}
+	return nil
+}
+
+// noteIndexer derives the search index and the links of a note from its content
+type noteIndexer struct {
+	searchRepo db.SearchRepository
+	linksRepo  db.LinksRepository
+}
+
+func (i noteIndexer) index(noteId uuid.UUID, content []byte) error {
+	if err := i.searchRepo.IndexNote(noteId, string(content)); err != nil {
+		return err
+	}
+	return i.linksRepo.SetLinks(noteId, linkTargets(content))
+}
+
+// linkTargets returns the targets of the wiki links in content, ids are normalized so they match the ids of notes
+func linkTargets(content []byte) []string {
+	links := markdown.WikiLinks(content)
+	targets := make([]string, 0, len(links))
+	for _, l := range links {
+		target := l.Target
+		if id, err := uuid.Parse(target); err == nil {
+			target = id.String()
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// linkTarget is the target links to a note are rewritten to. The id is used for titles that cannot be written in
+// a link or that other notes of the user have too, as links to such titles may resolve to another note.
+func (n notesServiceImpl) linkTarget(user models.User, noteId uuid.UUID, title string) string {
+	if strings.ContainsAny(title, "[]|\n") {
+		return noteId.String()
+	}
+	taken, err := n.linksRepo.HasOtherNoteTitled(user.Id, noteId, title)
+	if err != nil {
+		log.Printf("Could not check title of note %s for other notes: %s\n", noteId, err)
+		return noteId.String()
+	}
+	if taken {
+		return noteId.String()
+	}
+	return title
+}
+
+// updateLinksToNote rewrites the links to the old title of a renamed note in the notes linking to it. The rewritten
+// notes are queued as new versions merged with changes made meanwhile. Failures are only logged, as the note
+// is renamed already and links can still be fixed by hand.
+func (n notesServiceImpl) updateLinksToNote(user models.User, noteId uuid.UUID, oldTitle string, newTitle string, sources []models.Note) {
+	if len(sources) == 0 {
+		return
+	}
+	target := n.linkTarget(user, noteId, newTitle)
+	sourceIds := make([]uuid.UUID, 0, len(sources))
+	for _, s := range sources {
+		sourceIds = append(sourceIds, s.Id)
+	}
+	// Keeps the links resolved until the rewritten versions are stored
+	if err := n.linksRepo.RetargetLinks(sourceIds, oldTitle, target); err != nil {
+		log.Printf("Could not retarget links to note %s: %s\n", noteId, err)
+	}
+	for _, sourceId := range sourceIds {
+		version, content, err := n.diffingService.GetCurrentContent(sourceId)
+		if err != nil {
+			log.Printf("Could not read note %s linking to note %s: %s\n", sourceId, noteId, err)
+			continue
+		}
+		rewritten := markdown.ReplaceWikiLinks(content, func(l markdown.WikiLink) (string, bool) {
+			return target, strings.EqualFold(l.Target, oldTitle)
+		})
+		if bytes.Equal(rewritten, content) {
+			continue
+		}
+		if _, err := n.diffingService.QueueContent(sourceId, user.Id, rewritten, &version.Id, true); err != nil {
+			log.Printf("Could not update links of note %s to note %s: %s\n", sourceId, noteId, err)
+		}
+	}
+}
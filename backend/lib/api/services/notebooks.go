@@ -1,16 +1,45 @@
 package services
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
+	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/bongofriend/bongo-notes/backend/lib/api/db"
 	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/google/uuid"
 )
 
+var (
+	ErrNotebookNotFound        = errors.New("notebook not found")
+	ErrInvalidNotebookMetadata = errors.New("invalid notebook metadata")
+)
+
+var (
+	colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+	iconPattern  = regexp.MustCompile(`^[a-z0-9-]{1,64}$`)
+)
+
+const maxEmojiLength = 16
+
+// NotebookMetadataUpdate contains the metadata fields to change; nil fields are left untouched
+type NotebookMetadataUpdate struct {
+	Pinned    *bool
+	Favourite *bool
+	Color     *string
+	Icon      *string
+	Emoji     *string
+}
+
 type NotebookService interface {
 	FetchNotebooks(user models.User) ([]models.Notebook, error)
 	CreateNotebook(user models.User, title string, descroption string) error
+	GetNotebookDetails(user models.User, notebookId uuid.UUID) (models.NotebookDetails, error)
+	UpdateNotebookMetadata(user models.User, notebookId uuid.UUID, update NotebookMetadataUpdate) (models.Notebook, error)
+	ReorderNotebooks(user models.User, notebookIds []uuid.UUID) error
 }
 
 type notebooksServiceImpl struct {
@@ -35,3 +64,75 @@ func (n notebooksServiceImpl) CreateNotebook(user models.User, title string, des
 func (n notebooksServiceImpl) FetchNotebooks(user models.User) ([]models.Notebook, error) {
 	return n.notebooksRepo.FetchByUserId(user.Id)
 }
+
+func (n notebooksServiceImpl) GetNotebookDetails(user models.User, notebookId uuid.UUID) (models.NotebookDetails, error) {
+	details, err := n.notebooksRepo.GetNotebookDetails(user.Id, notebookId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NotebookDetails{}, fmt.Errorf("%w: %s", ErrNotebookNotFound, notebookId)
+	}
+	return details, err
+}
+
+func (n notebooksServiceImpl) UpdateNotebookMetadata(user models.User, notebookId uuid.UUID, update NotebookMetadataUpdate) (models.Notebook, error) {
+	details, err := n.GetNotebookDetails(user, notebookId)
+	if err != nil {
+		return models.Notebook{}, err
+	}
+	metadata := details.NotebookMetadata
+	if update.Pinned != nil {
+		metadata.Pinned = *update.Pinned
+	}
+	if update.Favourite != nil {
+		metadata.Favourite = *update.Favourite
+	}
+	if update.Color != nil {
+		metadata.Color = strings.TrimSpace(*update.Color)
+	}
+	if update.Icon != nil {
+		metadata.Icon = strings.TrimSpace(*update.Icon)
+	}
+	if update.Emoji != nil {
+		metadata.Emoji = strings.TrimSpace(*update.Emoji)
+	}
+	if err := validateNotebookMetadata(metadata); err != nil {
+		return models.Notebook{}, err
+	}
+	ok, err := n.notebooksRepo.UpdateNotebookMetadata(user.Id, notebookId, metadata)
+	if err != nil {
+		return models.Notebook{}, err
+	}
+	if !ok {
+		return models.Notebook{}, fmt.Errorf("%w: %s", ErrNotebookNotFound, notebookId)
+	}
+	notebook := details.Notebook
+	notebook.NotebookMetadata = metadata
+	return notebook, nil
+}
+
+func (n notebooksServiceImpl) ReorderNotebooks(user models.User, notebookIds []uuid.UUID) error {
+	seen := make(map[uuid.UUID]bool, len(notebookIds))
+	for _, id := range notebookIds {
+		if seen[id] {
+			return fmt.Errorf("%w: notebook %s listed more than once", ErrInvalidNotebookMetadata, id)
+		}
+		seen[id] = true
+	}
+	err := n.notebooksRepo.ReorderNotebooks(user.Id, notebookIds)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrNotebookNotFound, err)
+	}
+	return err
+}
+
+func validateNotebookMetadata(m models.NotebookMetadata) error {
+	if len(m.Color) > 0 && !colorPattern.MatchString(m.Color) {
+		return fmt.Errorf("%w: color %q is not of the form #rrggbb", ErrInvalidNotebookMetadata, m.Color)
+	}
+	if len(m.Icon) > 0 && !iconPattern.MatchString(m.Icon) {
+		return fmt.Errorf("%w: icon %q is not a valid icon name", ErrInvalidNotebookMetadata, m.Icon)
+	}
+	if utf8.RuneCountInString(m.Emoji) > maxEmojiLength {
+		return fmt.Errorf("%w: emoji is too long", ErrInvalidNotebookMetadata)
+	}
+	return nil
+}
@@ -1,7 +1,8 @@
 package services
 
 import (
-	"crypto/sha1"
+	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -10,67 +11,244 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/bongofriend/bongo-notes/backend/lib/api/db"
 	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
 	"github.com/bongofriend/bongo-notes/backend/lib/config"
+	"github.com/bongofriend/bongo-notes/backend/lib/markdown"
 	"github.com/google/uuid"
 )
 
+var (
+	ErrNoteNotFound        = errors.New("note not found")
+	ErrInvalidNoteMetadata = errors.New("invalid note metadata")
+	ErrUpdateFailed        = errors.New("update could not be stored")
+)
+
+// NoteUpdate contains the new content of a note and the versions it is based on
+type NoteUpdate struct {
+	Content string
+	// IfMatch lists the versions the note may be at, an empty list skips the check
+	IfMatch []uuid.UUID
+	// BaseVersionId is the version the content was edited from. Changes made since are merged into the content.
+	BaseVersionId *uuid.UUID
+}
+
+// MergeConflictError is returned if an update conflicts with the changes made since its base version
+type MergeConflictError struct {
+	Conflict models.MergeConflict
+}
+
+func (e MergeConflictError) Error() string {
+	return fmt.Sprintf("%s: %d conflicts between version %s and update based on version %s",
+		ErrMergeConflict, len(e.Conflict.Conflicts), e.Conflict.CurrentVersionId, e.Conflict.BaseVersionId)
+}
+
+func (e MergeConflictError) Unwrap() error {
+	return ErrMergeConflict
+}
+
+// NoteMetadataUpdate contains the metadata fields to change; nil fields are left untouched
+type NoteMetadataUpdate struct {
+	Title      *string
+	NotebookId *uuid.UUID
+}
+
 type NotesService interface {
 	AddNoteToNotebook(user models.User, notebookId uuid.UUID, noteTitle string, content string) error
-	FetchNotes(user models.User, notebookId uuid.UUID) ([]models.Note, error)
-	UpdateNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID, notebookIdnewContent string) error
-	GetNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID) ([]byte, error)
-	GetPatchedNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID, diffId uuid.UUID) ([]byte, error)
+	AddNoteFromTemplate(user models.User, notebookId uuid.UUID, templateId uuid.UUID, noteTitle string, variables map[string]string) error
+	// FetchNotes returns the notes of a notebook, only those tagged with all tags if any are given
+	FetchNotes(user models.User, notebookId uuid.UUID, tags []string) ([]models.Note, error)
+	// FetchAllNotes returns the notes of all notebooks of the user, only those tagged with all tags if any are given
+	FetchAllNotes(user models.User, tags []string) ([]models.Note, error)
+	// UpdateNote queues the content as new version of the note. If IfMatch is not empty, the current version
+	// of the note has to be one of its entries, otherwise ErrPreconditionFailed is returned. With a base version,
+	// changes made since are merged and a MergeConflictError is returned if they conflict.
+	UpdateNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID, update NoteUpdate) (models.DiffJob, error)
+	// UpdateNoteAndWait queues the content like UpdateNote and waits up to timeout for it to be stored.
+	// If it is not stored in time, ErrUpdatePending is returned together with the queued job.
+	UpdateNoteAndWait(ctx context.Context, user models.User, notebookId uuid.UUID, noteId uuid.UUID, update NoteUpdate, timeout time.Duration) (models.NoteUpdateResult, models.DiffJob, error)
+	// GetNote returns the most recent content of a note together with its version
+	GetNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID) (models.NoteVersion, []byte, error)
+	GetPatchedNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID, versionId uuid.UUID) (models.NoteVersion, []byte, error)
+	// RenderNote renders a version of a note as Markdown to sanitized HTML, nil renders the most recent version.
+	// The result is cached per version.
+	RenderNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID, versionId *uuid.UUID) (models.NoteVersion, []byte, error)
+	DeleteNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID) error
+	UpdateNoteMetadata(user models.User, notebookId uuid.UUID, noteId uuid.UUID, update NoteMetadataUpdate) (models.Note, error)
+	ListVersions(user models.User, notebookId uuid.UUID, noteId uuid.UUID, cursor *uuid.UUID, limit int) ([]models.NoteVersion, *uuid.UUID, error)
+	CompareVersions(user models.User, notebookId uuid.UUID, noteId uuid.UUID, from *uuid.UUID, to *uuid.UUID, contextLines int) (models.NoteComparison, error)
+	RevertNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID, versionId uuid.UUID) (models.DiffJob, error)
 }
 
 type notesServiceImpl struct {
-	config         config.Config
-	notebookRepo   db.NotebooksRepository
-	notesRepo      db.NotesRepository
-	diffingService DiffingService
+	config           config.Config
+	notebookRepo     db.NotebooksRepository
+	notesRepo        db.NotesRepository
+	linksRepo        db.LinksRepository
+	indexer          noteIndexer
+	diffingService   DiffingService
+	templatesService TemplatesService
 }
 
 // GetPatchedNote implements NotesService.
-func (n notesServiceImpl) GetPatchedNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID, diffId uuid.UUID) ([]byte, error) {
-	panic("unimplemented")
+func (n notesServiceImpl) GetPatchedNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID, versionId uuid.UUID) (models.NoteVersion, []byte, error) {
+	if err := n.checkNoteOwnership(user, notebookId, noteId); err != nil {
+		return models.NoteVersion{}, nil, err
+	}
+	return n.diffingService.GetVersionContent(noteId, versionId)
 }
 
-// GetNote implements NotesService.
-func (n notesServiceImpl) GetNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID) ([]byte, error) {
-	isPartOf, err := n.notesRepo.IsNotePartOfNotebook(user.Id, notebookId, noteId)
+// RenderNote implements NotesService.
+func (n notesServiceImpl) RenderNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID, versionId *uuid.UUID) (models.NoteVersion, []byte, error) {
+	var version models.NoteVersion
+	var content []byte
+	var err error
+	if versionId == nil {
+		version, content, err = n.GetNote(user, notebookId, noteId)
+	} else {
+		version, content, err = n.GetPatchedNote(user, notebookId, noteId, *versionId)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("could validate note ownershio: %w", err)
+		return models.NoteVersion{}, nil, err
 	}
-	if !isPartOf {
-		return nil, fmt.Errorf("user %s has not ownership of note %s", user.Id.String(), noteId.String())
+	cachePath := n.renderedFilePath(noteId, version.Id)
+	if rendered, err := os.ReadFile(cachePath); err == nil {
+		return version, rendered, nil
+	}
+	rendered, err := markdown.Render(content)
+	if err != nil {
+		return models.NoteVersion{}, nil, err
 	}
-	return n.readMostRecentNoteVersion(noteId)
+	// Versions never change, so a failed cache write only costs rendering again
+	if err := writeFileAtomically(cachePath, rendered); err != nil {
+		log.Printf("Could not cache rendered version %s: %s\n", version.Id, err)
+	}
+	return version, rendered, nil
+}
+
+// renderedFilePath is the cache of a rendered version, named after the renderer version to ignore results of older releases
+func (n notesServiceImpl) renderedFilePath(noteId uuid.UUID, versionId uuid.UUID) string {
+	return filepath.Join(n.config.NotesFolderPath, noteId.String(), "rendered", fmt.Sprintf("%s.v%d.html", versionId, markdown.RendererVersion))
 }
 
-func (n notesServiceImpl) readMostRecentNoteVersion(noteId uuid.UUID) ([]byte, error) {
-	notePath := filepath.Join(n.config.NotesFolderPath, noteId.String(), "recent")
-	stat, err := os.Stat(notePath)
+func writeFileAtomically(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Concurrent writers of the same file each use their own temporary file
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
 	if err != nil {
-		return nil, fmt.Errorf("could not read note: %w", err)
+		return err
 	}
-	if !stat.Mode().IsRegular() {
-		return nil, fmt.Errorf("%s is not a note", notePath)
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
 	}
-	file, err := os.Open(notePath)
 	if err != nil {
-		return nil, fmt.Errorf("could not open note: %w", err)
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// ListVersions implements NotesService.
+func (n notesServiceImpl) ListVersions(user models.User, notebookId uuid.UUID, noteId uuid.UUID, cursor *uuid.UUID, limit int) ([]models.NoteVersion, *uuid.UUID, error) {
+	if err := n.checkNoteOwnership(user, notebookId, noteId); err != nil {
+		return nil, nil, err
+	}
+	return n.diffingService.ListVersions(noteId, cursor, limit)
+}
+
+// CompareVersions implements NotesService.
+func (n notesServiceImpl) CompareVersions(user models.User, notebookId uuid.UUID, noteId uuid.UUID, from *uuid.UUID, to *uuid.UUID, contextLines int) (models.NoteComparison, error) {
+	if err := n.checkNoteOwnership(user, notebookId, noteId); err != nil {
+		return models.NoteComparison{}, err
+	}
+	return n.diffingService.CompareVersions(noteId, from, to, contextLines)
+}
+
+// DeleteNote implements NotesService.
+func (n notesServiceImpl) DeleteNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID) error {
+	if err := n.checkNoteOwnership(user, notebookId, noteId); err != nil {
+		return err
+	}
+	return n.diffingService.DeleteNote(noteId, func() error {
+		if err := n.notesRepo.DeleteNote(noteId); err != nil {
+			return err
+		}
+		return os.RemoveAll(filepath.Join(n.config.NotesFolderPath, noteId.String()))
+	})
+}
+
+// UpdateNoteMetadata implements NotesService.
+func (n notesServiceImpl) UpdateNoteMetadata(user models.User, notebookId uuid.UUID, noteId uuid.UUID, update NoteMetadataUpdate) (models.Note, error) {
+	if err := n.checkNoteOwnership(user, notebookId, noteId); err != nil {
+		return models.Note{}, err
 	}
-	content, err := io.ReadAll(file)
+	note, err := n.notesRepo.GetNote(noteId)
 	if err != nil {
-		return nil, err
+		return models.Note{}, err
+	}
+	title := note.Title
+	if update.Title != nil {
+		title = strings.TrimSpace(*update.Title)
+		if len(title) == 0 {
+			return models.Note{}, fmt.Errorf("%w: title was empty", ErrInvalidNoteMetadata)
+		}
+	}
+	targetNotebookId := notebookId
+	if update.NotebookId != nil && *update.NotebookId != notebookId {
+		hasNotebook, err := n.notebookRepo.HasNotebook(user.Id, *update.NotebookId)
+		if err != nil {
+			return models.Note{}, err
+		}
+		if !hasNotebook {
+			return models.Note{}, fmt.Errorf("%w: user %s has not ownership of notebook %s", ErrNotebookNotFound, user.Id, *update.NotebookId)
+		}
+		targetNotebookId = *update.NotebookId
+	}
+	// Links are looked up before the rename, afterwards they no longer resolve to the note
+	var linkingNotes []models.Note
+	renamed := !strings.EqualFold(title, note.Title)
+	if renamed {
+		if linkingNotes, err = n.linksRepo.GetBacklinks(user.Id, noteId); err != nil {
+			return models.Note{}, err
+		}
+	}
+	if err := n.notesRepo.UpdateNoteMetadata(noteId, targetNotebookId, title); err != nil {
+		return models.Note{}, err
+	}
+	if renamed {
+		n.updateLinksToNote(user, noteId, note.Title, title, linkingNotes)
+	}
+	return n.notesRepo.GetNote(noteId)
+}
+
+// GetNote implements NotesService.
+func (n notesServiceImpl) GetNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID) (models.NoteVersion, []byte, error) {
+	if err := n.checkNoteOwnership(user, notebookId, noteId); err != nil {
+		return models.NoteVersion{}, nil, err
 	}
-	return content, nil
+	return n.diffingService.GetCurrentContent(noteId)
+}
+
+func (n notesServiceImpl) checkNoteOwnership(user models.User, notebookId uuid.UUID, noteId uuid.UUID) error {
+	isPartOf, err := n.notesRepo.IsNotePartOfNotebook(user.Id, notebookId, noteId)
+	if err != nil {
+		return fmt.Errorf("could validate note ownershio: %w", err)
+	}
+	if !isPartOf {
+		return fmt.Errorf("%w: user %s has not ownership of note %s", ErrNoteNotFound, user.Id, noteId)
+	}
+	return nil
 }
 
 // FetchNotes implements NotesService.
-func (n notesServiceImpl) FetchNotes(user models.User, notebookId uuid.UUID) ([]models.Note, error) {
+func (n notesServiceImpl) FetchNotes(user models.User, notebookId uuid.UUID, tags []string) ([]models.Note, error) {
 	hasNotebook, err := n.notebookRepo.HasNotebook(user.Id, notebookId)
 	if err != nil {
 		return nil, err
@@ -78,13 +256,18 @@ func (n notesServiceImpl) FetchNotes(user models.User, notebookId uuid.UUID) ([]
 	if !hasNotebook {
 		return nil, fmt.Errorf("user %s has not ownership of notebook %s", user.Id, notebookId)
 	}
-	return n.notesRepo.GetNotesForNotebook(notebookId)
+	return n.notesRepo.GetNotesForNotebook(notebookId, tags)
+}
+
+// FetchAllNotes implements NotesService.
+func (n notesServiceImpl) FetchAllNotes(user models.User, tags []string) ([]models.Note, error) {
+	return n.notesRepo.GetNotesForUser(user.Id, tags)
 }
 
 // AddNoteToNotebook implements NotesService.
 func (n notesServiceImpl) AddNoteToNotebook(user models.User, notebookId uuid.UUID, noteTitle string, content string) error {
-	if isPlainText, err := isValidNote(content); err != nil || !isPlainText {
-		return errors.New("file could not be validated")
+	if err := validateNote(content, n.config.Notes.MaxSize); err != nil {
+		return err
 	}
 	hasNotebook, err := n.notebookRepo.HasNotebook(user.Id, notebookId)
 	if err != nil {
@@ -94,17 +277,32 @@ func (n notesServiceImpl) AddNoteToNotebook(user models.User, notebookId uuid.UU
 		return fmt.Errorf("user %d has not ownership of notebook %d", user.Id, notebookId)
 	}
 	noteId := uuid.New()
+	content = normalizeNote(content)
 	filePath, err := n.writeNewNoteToDisk(noteId, content)
 	if err != nil {
 		return err
 	}
-	return n.notesRepo.AddNote(notebookId, noteId, noteTitle, filePath)
+	stats := models.VersionStats{
+		Size:       int64(len(content)),
+		LineCount:  countLines([]byte(content)),
+		LinesAdded: countLines([]byte(content)),
+	}
+	if err := n.notesRepo.AddNote(notebookId, noteId, user.Id, noteTitle, filePath, stats); err != nil {
+		return err
+	}
+	if err := n.indexer.index(noteId, []byte(content)); err != nil {
+		log.Printf("Could not index note %s: %s\n", noteId, err)
+	}
+	return nil
 }
 
-// TODO
-func isValidNote(content string) (bool, error) {
-	log.Println(content)
-	return true, nil
+// AddNoteFromTemplate implements NotesService.
+func (n notesServiceImpl) AddNoteFromTemplate(user models.User, notebookId uuid.UUID, templateId uuid.UUID, noteTitle string, variables map[string]string) error {
+	title, content, err := n.templatesService.RenderTemplate(user, notebookId, templateId, noteTitle, variables)
+	if err != nil {
+		return err
+	}
+	return n.AddNoteToNotebook(user, notebookId, title, content)
 }
 
 func (n notesServiceImpl) writeNewNoteToDisk(noteId uuid.UUID, fileContent string) (string, error) {
@@ -118,7 +316,6 @@ func (n notesServiceImpl) writeNewNoteToDisk(noteId uuid.UUID, fileContent strin
 		return "", err
 	}
 	defer file.Close()
-	adjustLineBreak(&fileContent)
 	reader := strings.NewReader(fileContent)
 	_, err = io.Copy(file, reader)
 	if err != nil {
@@ -128,73 +325,141 @@ func (n notesServiceImpl) writeNewNoteToDisk(noteId uuid.UUID, fileContent strin
 }
 
 // UpdateNote implements NotesService.
-func (n notesServiceImpl) UpdateNote(user models.User, notebookdId uuid.UUID, noteId uuid.UUID, newContent string) error {
+func (n notesServiceImpl) UpdateNote(user models.User, notebookdId uuid.UUID, noteId uuid.UUID, update NoteUpdate) (models.DiffJob, error) {
 	isPartOfNotebook, err := n.notesRepo.IsNotePartOfNotebook(user.Id, notebookdId, noteId)
 	if err != nil {
-		return err
+		return models.DiffJob{}, err
 	}
 	if !isPartOfNotebook {
-		return fmt.Errorf("wrong note access: User-Id %s Notebook-Id %s Note-Id %s", user.Id, notebookdId, noteId)
+		return models.DiffJob{}, fmt.Errorf("wrong note access: User-Id %s Notebook-Id %s Note-Id %s", user.Id, notebookdId, noteId)
+	}
+	if err := validateNote(update.Content, n.config.Notes.MaxSize); err != nil {
+		return models.DiffJob{}, err
 	}
-	ok, err := isValidNote(newContent)
+	newContent := normalizeNote(update.Content)
+	baseVersionId, err := n.baseVersion(noteId, update.IfMatch)
 	if err != nil {
-		return err
+		return models.DiffJob{}, err
 	}
-	if !ok {
-		return fmt.Errorf("invalid content for note %s", noteId)
+	if update.BaseVersionId == nil || baseVersionId != nil {
+		return n.diffingService.QueueContent(noteId, user.Id, []byte(newContent), baseVersionId, false)
 	}
-	newContentPath, err := n.writeTempNoteToDisk(newContent)
+	// Conflicts visible already are reported right away, the worker merges again with the note as it is then
+	if err := n.checkMerge(noteId, *update.BaseVersionId, newContent); err != nil {
+		return models.DiffJob{}, err
+	}
+	return n.diffingService.QueueContent(noteId, user.Id, []byte(newContent), update.BaseVersionId, true)
+}
+
+// checkMerge returns a MergeConflictError if the content conflicts with the changes made since the base version
+func (n notesServiceImpl) checkMerge(noteId uuid.UUID, baseVersionId uuid.UUID, content string) error {
+	current, result, err := n.diffingService.MergeContent(noteId, baseVersionId, []byte(content))
 	if err != nil {
 		return err
 	}
-	n.diffingService.QueueFile(newContentPath, noteId)
-	return nil
+	if result.Clean() {
+		return nil
+	}
+	return MergeConflictError{
+		Conflict: models.MergeConflict{
+			BaseVersionId:    baseVersionId,
+			CurrentVersionId: current.Id,
+			Content:          string(result.Content),
+			Conflicts:        result.Conflicts,
+		},
+	}
 }
 
-func (n notesServiceImpl) writeTempNoteToDisk(content string) (string, error) {
-	notesTempPath := filepath.Join(n.config.NotesFolderPath, "temp")
-	if err := os.MkdirAll(notesTempPath, 0755); err != nil {
-		return "", err
-	}
-	contentHash, err := getHashByContent(strings.NewReader(content))
-	if err != nil {
-		return "", err
+// baseVersion checks the current version of a note against the versions an update is allowed to be based on.
+// The check is repeated by the diffing worker, as the note can still change until the update is processed.
+func (n notesServiceImpl) baseVersion(noteId uuid.UUID, ifMatch []uuid.UUID) (*uuid.UUID, error) {
+	if len(ifMatch) == 0 {
+		return nil, nil
 	}
-	newNoteContentPath := filepath.Join(notesTempPath, contentHash)
-	file, err := os.Create(newNoteContentPath)
+	latest, err := n.diffingService.GetLatestVersion(noteId)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer file.Close()
-	adjustLineBreak(&content)
-	buf := make([]byte, 1024*1024)
-	if _, err := io.CopyBuffer(file, strings.NewReader(content), buf); err != nil {
-		return "", err
+	for _, versionId := range ifMatch {
+		if versionId == latest.Id {
+			return &latest.Id, nil
+		}
 	}
-	return newNoteContentPath, nil
+	return nil, fmt.Errorf("%w: note %s is at version %s", ErrPreconditionFailed, noteId, latest.Id)
 }
 
-func getHashByContent(r io.Reader) (string, error) {
-	buff := make([]byte, 1024*1024)
-	hash := sha1.New()
-	if _, err := io.CopyBuffer(hash, r, buff); err != nil {
-		return "", err
+// UpdateNoteAndWait implements NotesService.
+func (n notesServiceImpl) UpdateNoteAndWait(ctx context.Context, user models.User, notebookId uuid.UUID, noteId uuid.UUID, update NoteUpdate, timeout time.Duration) (models.NoteUpdateResult, models.DiffJob, error) {
+	job, err := n.UpdateNote(user, notebookId, noteId, update)
+	if err != nil {
+		return models.NoteUpdateResult{}, models.DiffJob{}, err
+	}
+	job, err = n.diffingService.WaitForJob(ctx, job.Id, timeout)
+	if err != nil {
+		return models.NoteUpdateResult{}, job, err
+	}
+	// The stored content is the one passed to UpdateNote
+	newContent := normalizeNote(update.Content)
+	if job.State == models.JobRejected && job.Merge {
+		// The content of the job is gone, so the conflict is determined again
+		if err := n.checkMerge(noteId, *job.BaseVersionId, newContent); err != nil {
+			return models.NoteUpdateResult{}, job, err
+		}
+	}
+	if job.State == models.JobRejected {
+		return models.NoteUpdateResult{}, job, fmt.Errorf("%w: %s", ErrPreconditionFailed, job.Error)
+	}
+	if job.State == models.JobDead || job.VersionId == nil {
+		return models.NoteUpdateResult{}, job, fmt.Errorf("%w: %s", ErrUpdateFailed, job.Error)
 	}
-	return hex.EncodeToString(hash.Sum(nil)), nil
+	version, err := n.diffingService.GetVersion(noteId, *job.VersionId)
+	if err != nil {
+		return models.NoteUpdateResult{}, job, err
+	}
+	note, err := n.notesRepo.GetNote(noteId)
+	if err != nil {
+		return models.NoteUpdateResult{}, job, err
+	}
+	content := []byte(newContent)
+	if job.Merge {
+		// Merged content differs from the one passed to UpdateNote
+		if _, content, err = n.diffingService.GetVersionContent(noteId, version.Id); err != nil {
+			return models.NoteUpdateResult{}, job, err
+		}
+	}
+	hash := sha256.Sum256(content)
+	return models.NoteUpdateResult{
+		JobId:       job.Id,
+		VersionId:   version.Id,
+		Version:     version.Version,
+		CreatedAt:   version.CreatedAt,
+		UpdatedAt:   note.UpdatedAt,
+		ContentHash: hex.EncodeToString(hash[:]),
+	}, job, nil
 }
 
-func adjustLineBreak(s *string) {
-	if strings.HasSuffix(*s, "\n") {
-		return
+// RevertNote implements NotesService. The content of the version is queued as a new version,
+// so the revert itself becomes part of the history.
+func (n notesServiceImpl) RevertNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID, versionId uuid.UUID) (models.DiffJob, error) {
+	_, content, err := n.GetPatchedNote(user, notebookId, noteId, versionId)
+	if err != nil {
+		return models.DiffJob{}, err
 	}
-	*s = *s + "\n"
+	revertedContent := normalizeNote(string(content))
+	return n.diffingService.QueueContent(noteId, user.Id, []byte(revertedContent), nil, false)
 }
 
-func NewNotesService(c config.Config, diffService DiffingService, notesRepo db.NotesRepository, notebookRepo db.NotebooksRepository) NotesService {
+func NewNotesService(c config.Config, diffService DiffingService, templatesService TemplatesService, notesRepo db.NotesRepository, notebookRepo db.NotebooksRepository, searchRepo db.SearchRepository, linksRepo db.LinksRepository) NotesService {
 	return notesServiceImpl{
-		config:         c,
-		notebookRepo:   notebookRepo,
-		notesRepo:      notesRepo,
-		diffingService: diffService,
+		config:       c,
+		notebookRepo: notebookRepo,
+		notesRepo:    notesRepo,
+		linksRepo:    linksRepo,
+		indexer: noteIndexer{
+			searchRepo: searchRepo,
+			linksRepo:  linksRepo,
+		},
+		diffingService:   diffService,
+		templatesService: templatesService,
 	}
 }
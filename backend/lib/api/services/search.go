@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/db"
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/bongofriend/bongo-notes/backend/lib/search"
+)
+
+var ErrInvalidSearchQuery = errors.New("invalid search query")
+
+type SearchService interface {
+	// Search returns the notes of the user matching all terms of the query, best matches first.
+	// See package search for the syntax of queries.
+	Search(user models.User, query string, limit int, offset int) ([]models.SearchResult, error)
+	// Reindex indexes the current content and links of all notes and returns the number of indexed notes.
+	// The sizes of the notes are corrected as well, notes stored before sizes were recorded have none.
+	Reindex() (int, error)
+}
+
+type searchServiceImpl struct {
+	searchRepo     db.SearchRepository
+	indexer        noteIndexer
+	diffingRepo    db.DiffingRepository
+	diffingService DiffingService
+}
+
+func NewSearchService(searchRepo db.SearchRepository, linksRepo db.LinksRepository, diffingRepo db.DiffingRepository, diffingService DiffingService) SearchService {
+	return searchServiceImpl{
+		searchRepo: searchRepo,
+		indexer: noteIndexer{
+			searchRepo: searchRepo,
+			linksRepo:  linksRepo,
+		},
+		diffingRepo:    diffingRepo,
+		diffingService: diffingService,
+	}
+}
+
+// Search implements SearchService. Queries that cannot be parsed fail with ErrInvalidSearchQuery wrapping
+// the search.SyntaxError pointing at the invalid term.
+func (s searchServiceImpl) Search(user models.User, query string, limit int, offset int) ([]models.SearchResult, error) {
+	parsed, err := search.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSearchQuery, err)
+	}
+	return s.searchRepo.Search(user.Id, parsed, limit, offset)
+}
+
+// Reindex implements SearchService. Notes that cannot be read are skipped, so one broken note does not
+// keep the others from being indexed.
+func (s searchServiceImpl) Reindex() (int, error) {
+	noteIds, err := s.searchRepo.GetNoteIds()
+	if err != nil {
+		return 0, err
+	}
+	indexed := 0
+	for _, noteId := range noteIds {
+		version, content, err := s.diffingService.GetCurrentContent(noteId)
+		if err != nil {
+			log.Printf("Could not read note %s: %s\n", noteId, err)
+			continue
+		}
+		if err := s.indexer.index(noteId, content); err != nil {
+			return indexed, err
+		}
+		if err := s.diffingRepo.UpdateCurrentSize(noteId, version.Id, int64(len(content)), countLines(content)); err != nil {
+			return indexed, err
+		}
+		indexed++
+	}
+	if err := s.searchRepo.RemoveDeletedNotes(); err != nil {
+		return indexed, err
+	}
+	return indexed, nil
+}
@@ -12,6 +12,12 @@ type servicesContainerImpl struct {
 	notebooksService NotebookService
 	notesService     NotesService
 	diffingService   DiffingService
+	templatesService TemplatesService
+	jobsService      JobsService
+	collabService    CollabService
+	searchService    SearchService
+	tagsService      TagsService
+	linksService     LinksService
 }
 
 type ServicesContainer interface {
@@ -19,16 +25,24 @@ type ServicesContainer interface {
 	NotebooksService() NotebookService
 	NotesService() NotesService
 	DiffingService() DiffingService
+	TemplatesService() TemplatesService
+	JobsService() JobsService
+	CollabService() CollabService
+	SearchService() SearchService
+	TagsService() TagsService
+	LinksService() LinksService
 	Shutdown(chan struct{})
 	Init(appContext context.Context)
 }
 
 // Init implements ServicesContainer.
 func (s servicesContainerImpl) Init(appContext context.Context) {
-	go s.diffingService.Start(appContext)
+	s.diffingService.Start(appContext)
+	s.collabService.Start(appContext)
 }
 
 func (s servicesContainerImpl) Shutdown(doneCh chan struct{}) {
+	<-s.collabService.done()
 	<-s.diffingService.done()
 	doneCh <- struct{}{}
 }
@@ -49,16 +63,47 @@ func (s servicesContainerImpl) DiffingService() DiffingService {
 	return s.diffingService
 }
 
+func (s servicesContainerImpl) TemplatesService() TemplatesService {
+	return s.templatesService
+}
+
+func (s servicesContainerImpl) JobsService() JobsService {
+	return s.jobsService
+}
+
+func (s servicesContainerImpl) CollabService() CollabService {
+	return s.collabService
+}
+
+func (s servicesContainerImpl) SearchService() SearchService {
+	return s.searchService
+}
+
+func (s servicesContainerImpl) TagsService() TagsService {
+	return s.tagsService
+}
+
+func (s servicesContainerImpl) LinksService() LinksService {
+	return s.linksService
+}
+
 func NewServicesContainer(c config.Config, r db.RepositoryContainer) ServicesContainer {
-	diffingService := NewDiffingService(c, r.DiffingRespository())
+	diffingService := NewDiffingService(c, r.DiffingRespository(), r.JobsRepository(), r.SearchRepository(), r.LinksRepository())
 	authService := NewAuthService(c, r.UserRepository())
 	notebooksService := NewNotebooksService(r.NotebooksRepository())
-	notesService := NewNotesService(c, diffingService, r.NotesRepository(), r.NotebooksRepository())
+	templatesService := NewTemplatesService(r.TemplatesRepository(), r.NotebooksRepository())
+	notesService := NewNotesService(c, diffingService, templatesService, r.NotesRepository(), r.NotebooksRepository(), r.SearchRepository(), r.LinksRepository())
 
 	return servicesContainerImpl{
 		authService:      authService,
 		notebooksService: notebooksService,
 		notesService:     notesService,
 		diffingService:   diffingService,
+		templatesService: templatesService,
+		jobsService:      NewJobsService(r.JobsRepository()),
+		collabService:    NewCollabService(c, r.NotesRepository(), diffingService),
+		searchService:    NewSearchService(r.SearchRepository(), r.LinksRepository(), r.DiffingRespository(), diffingService),
+		tagsService:      NewTagsService(r.TagsRepository(), r.NotesRepository()),
+		linksService:     NewLinksService(r.LinksRepository(), r.NotesRepository()),
 	}
 }
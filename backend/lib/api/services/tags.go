@@ -0,0 +1,167 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/db"
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTagNotFound = errors.New("tag not found")
+	ErrInvalidTag  = errors.New("invalid tag")
+	ErrTagExists   = errors.New("tag already exists")
+)
+
+const maxTagLength = 64
+
+type TagsService interface {
+	// FetchTags returns the tags of the user with the number of notes tagged with them
+	FetchTags(user models.User) ([]models.Tag, error)
+	// TagNote adds tags to a note and returns all tags of the note. Tags the user does not have yet are created.
+	TagNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID, names []string) ([]string, error)
+	// UntagNote removes a tag from a note and returns the remaining tags of the note
+	UntagNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID, name string) ([]string, error)
+	// RenameTag renames a tag on all notes. If the name is taken by another tag, ErrTagExists is returned
+	// and the tags can be merged instead.
+	RenameTag(user models.User, tagId uuid.UUID, name string) (models.Tag, error)
+	// MergeTag tags all notes tagged with a tag with the target tag instead, deletes the tag and returns the target
+	MergeTag(user models.User, tagId uuid.UUID, targetId uuid.UUID) (models.Tag, error)
+	DeleteTag(user models.User, tagId uuid.UUID) error
+}
+
+type tagsServiceImpl struct {
+	tagsRepo  db.TagsRepository
+	notesRepo db.NotesRepository
+}
+
+func NewTagsService(tagsRepo db.TagsRepository, notesRepo db.NotesRepository) TagsService {
+	return tagsServiceImpl{
+		tagsRepo:  tagsRepo,
+		notesRepo: notesRepo,
+	}
+}
+
+// FetchTags implements TagsService.
+func (t tagsServiceImpl) FetchTags(user models.User) ([]models.Tag, error) {
+	return t.tagsRepo.GetTags(user.Id)
+}
+
+// TagNote implements TagsService.
+func (t tagsServiceImpl) TagNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: no tags given", ErrInvalidTag)
+	}
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		name, err := normalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, name)
+	}
+	if err := t.checkNoteOwnership(user, notebookId, noteId); err != nil {
+		return nil, err
+	}
+	if err := t.tagsRepo.AddTagsToNote(user.Id, noteId, normalized); err != nil {
+		return nil, err
+	}
+	return t.tagsRepo.GetNoteTags(noteId)
+}
+
+// UntagNote implements TagsService.
+func (t tagsServiceImpl) UntagNote(user models.User, notebookId uuid.UUID, noteId uuid.UUID, name string) ([]string, error) {
+	if err := t.checkNoteOwnership(user, notebookId, noteId); err != nil {
+		return nil, err
+	}
+	removed, err := t.tagsRepo.RemoveTagFromNote(user.Id, noteId, strings.TrimSpace(name))
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, fmt.Errorf("%w: note %s is not tagged with %q", ErrTagNotFound, noteId, name)
+	}
+	return t.tagsRepo.GetNoteTags(noteId)
+}
+
+// RenameTag implements TagsService.
+func (t tagsServiceImpl) RenameTag(user models.User, tagId uuid.UUID, name string) (models.Tag, error) {
+	name, err := normalizeTag(name)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	existing, err := t.tagsRepo.GetTagByName(user.Id, name)
+	if err == nil && existing.Id != tagId {
+		return models.Tag{}, fmt.Errorf("%w: %q, merge the tags instead", ErrTagExists, existing.Name)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.Tag{}, err
+	}
+	renamed, err := t.tagsRepo.RenameTag(user.Id, tagId, name)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	if !renamed {
+		return models.Tag{}, fmt.Errorf("%w: %s", ErrTagNotFound, tagId)
+	}
+	return t.tagsRepo.GetTag(user.Id, tagId)
+}
+
+// MergeTag implements TagsService.
+func (t tagsServiceImpl) MergeTag(user models.User, tagId uuid.UUID, targetId uuid.UUID) (models.Tag, error) {
+	if tagId == targetId {
+		return models.Tag{}, fmt.Errorf("%w: tag %s cannot be merged into itself", ErrInvalidTag, tagId)
+	}
+	merged, err := t.tagsRepo.MergeTag(user.Id, tagId, targetId)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	if !merged {
+		return models.Tag{}, fmt.Errorf("%w: %s or %s", ErrTagNotFound, tagId, targetId)
+	}
+	return t.tagsRepo.GetTag(user.Id, targetId)
+}
+
+// DeleteTag implements TagsService.
+func (t tagsServiceImpl) DeleteTag(user models.User, tagId uuid.UUID) error {
+	deleted, err := t.tagsRepo.DeleteTag(user.Id, tagId)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("%w: %s", ErrTagNotFound, tagId)
+	}
+	return nil
+}
+
+func (t tagsServiceImpl) checkNoteOwnership(user models.User, notebookId uuid.UUID, noteId uuid.UUID) error {
+	isPartOf, err := t.notesRepo.IsNotePartOfNotebook(user.Id, notebookId, noteId)
+	if err != nil {
+		return err
+	}
+	if !isPartOf {
+		return fmt.Errorf("%w: user %s has not ownership of note %s", ErrNoteNotFound, user.Id, noteId)
+	}
+	return nil
+}
+
+// normalizeTag trims a tag name and checks that it is not empty, not too long and free of control characters
+func normalizeTag(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return "", fmt.Errorf("%w: tag is empty", ErrInvalidTag)
+	}
+	if utf8.RuneCountInString(name) > maxTagLength {
+		return "", fmt.Errorf("%w: tag %q is longer than %d characters", ErrInvalidTag, name, maxTagLength)
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("%w: tag %q contains control characters", ErrInvalidTag, name)
+	}
+	return name, nil
+}
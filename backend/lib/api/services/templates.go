@@ -0,0 +1,162 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/api/db"
+	"github.com/bongofriend/bongo-notes/backend/lib/api/models"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrInvalidTemplate  = errors.New("template name or content was empty")
+)
+
+// Placeholders are written as {{name}}; unknown names are left untouched
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+type TemplatesService interface {
+	FetchTemplates(user models.User, notebookId *uuid.UUID) ([]models.NoteTemplate, error)
+	GetTemplate(user models.User, templateId uuid.UUID) (models.NoteTemplate, error)
+	CreateTemplate(user models.User, notebookId *uuid.UUID, name string, title string, content string) (models.NoteTemplate, error)
+	UpdateTemplate(user models.User, templateId uuid.UUID, name string, title string, content string) error
+	DeleteTemplate(user models.User, templateId uuid.UUID) error
+	RenderTemplate(user models.User, notebookId uuid.UUID, templateId uuid.UUID, noteTitle string, variables map[string]string) (string, string, error)
+}
+
+type templatesServiceImpl struct {
+	templatesRepo db.TemplatesRepository
+	notebooksRepo db.NotebooksRepository
+}
+
+func NewTemplatesService(templatesRepo db.TemplatesRepository, notebooksRepo db.NotebooksRepository) TemplatesService {
+	return templatesServiceImpl{
+		templatesRepo: templatesRepo,
+		notebooksRepo: notebooksRepo,
+	}
+}
+
+// FetchTemplates implements TemplatesService.
+func (t templatesServiceImpl) FetchTemplates(user models.User, notebookId *uuid.UUID) ([]models.NoteTemplate, error) {
+	if notebookId != nil {
+		if err := t.checkNotebookOwnership(user, *notebookId); err != nil {
+			return nil, err
+		}
+	}
+	return t.templatesRepo.FetchTemplates(user.Id, notebookId)
+}
+
+// GetTemplate implements TemplatesService.
+func (t templatesServiceImpl) GetTemplate(user models.User, templateId uuid.UUID) (models.NoteTemplate, error) {
+	template, err := t.templatesRepo.GetTemplate(user.Id, templateId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NoteTemplate{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, templateId)
+	}
+	return template, err
+}
+
+// CreateTemplate implements TemplatesService.
+func (t templatesServiceImpl) CreateTemplate(user models.User, notebookId *uuid.UUID, name string, title string, content string) (models.NoteTemplate, error) {
+	if err := validateTemplate(name, content); err != nil {
+		return models.NoteTemplate{}, err
+	}
+	if notebookId != nil {
+		if err := t.checkNotebookOwnership(user, *notebookId); err != nil {
+			return models.NoteTemplate{}, err
+		}
+	}
+	templateId, err := t.templatesRepo.CreateTemplate(user.Id, notebookId, strings.TrimSpace(name), title, content)
+	if err != nil {
+		return models.NoteTemplate{}, err
+	}
+	return t.templatesRepo.GetTemplate(user.Id, templateId)
+}
+
+// UpdateTemplate implements TemplatesService.
+func (t templatesServiceImpl) UpdateTemplate(user models.User, templateId uuid.UUID, name string, title string, content string) error {
+	if err := validateTemplate(name, content); err != nil {
+		return err
+	}
+	ok, err := t.templatesRepo.UpdateTemplate(user.Id, templateId, strings.TrimSpace(name), title, content)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, templateId)
+	}
+	return nil
+}
+
+// DeleteTemplate implements TemplatesService.
+func (t templatesServiceImpl) DeleteTemplate(user models.User, templateId uuid.UUID) error {
+	ok, err := t.templatesRepo.DeleteTemplate(user.Id, templateId)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, templateId)
+	}
+	return nil
+}
+
+// RenderTemplate implements TemplatesService. It returns the title and content of a new note
+// created from the template in the given notebook.
+func (t templatesServiceImpl) RenderTemplate(user models.User, notebookId uuid.UUID, templateId uuid.UUID, noteTitle string, variables map[string]string) (string, string, error) {
+	template, err := t.GetTemplate(user, templateId)
+	if err != nil {
+		return "", "", err
+	}
+	if template.NotebookId != nil && *template.NotebookId != notebookId {
+		return "", "", fmt.Errorf("%w: %s is not available in notebook %s", ErrTemplateNotFound, templateId, notebookId)
+	}
+	now := time.Now()
+	values := make(map[string]string, len(variables)+4)
+	for k, v := range variables {
+		values[k] = v
+	}
+	values["date"] = now.Format(time.DateOnly)
+	values["time"] = now.Format("15:04")
+	values["username"] = user.Username
+	values["title"] = noteTitle
+
+	title := strings.TrimSpace(noteTitle)
+	if len(title) == 0 {
+		title = substitutePlaceholders(template.Title, values)
+		values["title"] = title
+	}
+	return title, substitutePlaceholders(template.Content, values), nil
+}
+
+func (t templatesServiceImpl) checkNotebookOwnership(user models.User, notebookId uuid.UUID) error {
+	hasNotebook, err := t.notebooksRepo.HasNotebook(user.Id, notebookId)
+	if err != nil {
+		return err
+	}
+	if !hasNotebook {
+		return fmt.Errorf("%w: user %s has not ownership of notebook %s", ErrNotebookNotFound, user.Id, notebookId)
+	}
+	return nil
+}
+
+func validateTemplate(name string, content string) error {
+	if len(strings.TrimSpace(name)) == 0 || len(content) == 0 {
+		return ErrInvalidTemplate
+	}
+	return nil
+}
+
+func substitutePlaceholders(s string, values map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return match
+	})
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidNoteContent = errors.New("invalid note content")
+	ErrNoteTooLarge       = errors.New("note is too large")
+)
+
+// sniffLength is the number of bytes http.DetectContentType looks at
+const sniffLength = 512
+
+// validateNote checks that content is plain text of at most maxSize bytes
+func validateNote(content string, maxSize int64) error {
+	if maxSize > 0 && int64(len(content)) > maxSize {
+		return fmt.Errorf("%w: note has %d bytes, at most %d bytes are allowed", ErrNoteTooLarge, len(content), maxSize)
+	}
+	if !utf8.ValidString(content) {
+		return fmt.Errorf("%w: content is not valid UTF-8", ErrInvalidNoteContent)
+	}
+	if i := strings.IndexByte(content, 0); i >= 0 {
+		return fmt.Errorf("%w: content contains a NUL byte at offset %d", ErrInvalidNoteContent, i)
+	}
+	// Control characters and signatures of binary formats are only looked for at the start, like browsers do
+	sniffed := content[:min(len(content), sniffLength)]
+	if contentType := http.DetectContentType([]byte(sniffed)); !strings.HasPrefix(contentType, "text/") {
+		return fmt.Errorf("%w: content looks like %s instead of text", ErrInvalidNoteContent, contentType)
+	}
+	return nil
+}
+
+// normalizeNote converts all line endings to \n and terminates the last line
+func normalizeNote(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content
+}
@@ -14,8 +14,8 @@ func WriteJson[T any](w http.ResponseWriter, statusCode int, data T) {
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(statusCode)
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
 	w.Write(buf)
 }
 
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -17,6 +18,30 @@ type Config struct {
 	IncludeSwagger  bool
 	JwtSecret       string `mapstruture:"jwtSecret"`
 	NotesFolderPath string `mapstructure:"notesPath"`
+	Notes           struct {
+		// MaxSize is the maximum size of a note in bytes
+		MaxSize int64 `mapstructure:"maxSize"`
+	} `mapstructure:"notes"`
+	Snapshots struct {
+		// Interval is the number of versions after which a full snapshot is stored
+		Interval int `mapstructure:"interval"`
+		// MaxDiffBytes is the size of accumulated diffs after which a full snapshot is stored
+		MaxDiffBytes int64 `mapstructure:"maxDiffBytes"`
+	} `mapstructure:"snapshots"`
+	Jobs struct {
+		// MaxAttempts is the number of attempts after which a failing job is not retried anymore
+		MaxAttempts int `mapstructure:"maxAttempts"`
+		// RetryDelay is the delay before the first retry, it doubles with every further attempt
+		RetryDelay time.Duration `mapstructure:"retryDelay"`
+		// Workers is the number of notes whose updates are processed in parallel
+		Workers int `mapstructure:"workers"`
+		// MaxQueueDepth is the number of queued updates after which further updates are rejected
+		MaxQueueDepth int `mapstructure:"maxQueueDepth"`
+	} `mapstructure:"jobs"`
+	Collab struct {
+		// CheckpointInterval is the interval in which sessions of notes edited together are stored as new version
+		CheckpointInterval time.Duration `mapstructure:"checkpointInterval"`
+	} `mapstructure:"collab"`
 }
 
 func LoadConfig(configPath string) (Config, error) {
@@ -24,6 +49,14 @@ func LoadConfig(configPath string) (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
+	viper.SetDefault("notes.maxSize", 1024*1024)
+	viper.SetDefault("snapshots.interval", 50)
+	viper.SetDefault("snapshots.maxDiffBytes", 1024*1024)
+	viper.SetDefault("jobs.maxAttempts", 5)
+	viper.SetDefault("jobs.retryDelay", "2s")
+	viper.SetDefault("jobs.workers", 4)
+	viper.SetDefault("jobs.maxQueueDepth", 1000)
+	viper.SetDefault("collab.checkpointInterval", "30s")
 	viper.SetConfigFile(configPath)
 	if err := viper.ReadInConfig(); err != nil {
 		return Config{}, err
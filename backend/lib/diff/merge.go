@@ -0,0 +1,152 @@
+package diff
+
+import (
+	"bytes"
+	"slices"
+)
+
+// Conflict is a region of the base content changed differently on both sides of a merge.
+// BaseStart is the 1-based line of the base content the region starts at.
+type Conflict struct {
+	BaseStart int      `json:"baseStart"`
+	Base      []string `json:"base"`
+	Current   []string `json:"current"`
+	Incoming  []string `json:"incoming"`
+}
+
+// MergeResult is the outcome of a three-way merge. If there are conflicts, Content contains
+// every conflicting region between conflict markers.
+type MergeResult struct {
+	Content   []byte
+	Conflicts []Conflict
+}
+
+func (m MergeResult) Clean() bool {
+	return len(m.Conflicts) == 0
+}
+
+// change replaces the base lines [start, end) with lines
+type change struct {
+	start int
+	end   int
+	lines []string
+}
+
+// Merge combines the changes made from base to current with the changes made from base to incoming.
+// Changes of both sides touching the same lines of base conflict unless they lead to the same lines.
+// The labels name the sides in the conflict markers.
+func Merge(base []byte, current []byte, incoming []byte, currentLabel string, incomingLabel string) MergeResult {
+	baseLines := SplitLines(base)
+	currentLines := SplitLines(current)
+	incomingLines := SplitLines(incoming)
+	currentChanges := changes(currentLines, Compute(baseLines, currentLines))
+	incomingChanges := changes(incomingLines, Compute(baseLines, incomingLines))
+
+	var buf bytes.Buffer
+	conflicts := make([]Conflict, 0)
+	position := 0
+	i, j := 0, 0
+	for i < len(currentChanges) || j < len(incomingChanges) {
+		// A region starts with the first pending change and grows while changes of either side touch it
+		var start, end int
+		if j >= len(incomingChanges) || (i < len(currentChanges) && currentChanges[i].start <= incomingChanges[j].start) {
+			start, end = currentChanges[i].start, currentChanges[i].end
+		} else {
+			start, end = incomingChanges[j].start, incomingChanges[j].end
+		}
+		fromCurrent, fromIncoming := i, j
+		for {
+			if i < len(currentChanges) && currentChanges[i].start <= end {
+				end = max(end, currentChanges[i].end)
+				i++
+			} else if j < len(incomingChanges) && incomingChanges[j].start <= end {
+				end = max(end, incomingChanges[j].end)
+				j++
+			} else {
+				break
+			}
+		}
+		writeLines(&buf, baseLines[position:start])
+		position = end
+		currentRegion := applyChanges(baseLines, start, end, currentChanges[fromCurrent:i])
+		incomingRegion := applyChanges(baseLines, start, end, incomingChanges[fromIncoming:j])
+		switch {
+		case fromIncoming == j:
+			writeLines(&buf, currentRegion)
+		case fromCurrent == i, slices.Equal(currentRegion, incomingRegion):
+			writeLines(&buf, incomingRegion)
+		default:
+			conflict := Conflict{
+				BaseStart: start + 1,
+				Base:      slices.Clone(baseLines[start:end]),
+				Current:   currentRegion,
+				Incoming:  incomingRegion,
+			}
+			conflicts = append(conflicts, conflict)
+			writeConflict(&buf, conflict, currentLabel, incomingLabel)
+		}
+	}
+	writeLines(&buf, baseLines[position:])
+	return MergeResult{
+		Content:   buf.Bytes(),
+		Conflicts: conflicts,
+	}
+}
+
+// changes groups the edits transforming base into other into replacements of base lines
+func changes(other []string, edits []Edit) []change {
+	result := make([]change, 0)
+	for k := 0; k < len(edits); {
+		if edits[k].Kind == Equal {
+			k++
+			continue
+		}
+		c := change{start: edits[k].OldIndex, end: edits[k].OldIndex, lines: make([]string, 0)}
+		for ; k < len(edits) && edits[k].Kind != Equal; k++ {
+			if edits[k].Kind == Delete {
+				c.end++
+			} else {
+				c.lines = append(c.lines, other[edits[k].NewIndex])
+			}
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+// applyChanges returns the base lines [start, end) with the changes applied
+func applyChanges(base []string, start int, end int, changes []change) []string {
+	lines := make([]string, 0, end-start)
+	position := start
+	for _, c := range changes {
+		lines = append(lines, base[position:c.start]...)
+		lines = append(lines, c.lines...)
+		position = c.end
+	}
+	return append(lines, base[position:end]...)
+}
+
+func writeLines(buf *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		buf.WriteString(line)
+	}
+}
+
+// writeConflict writes a conflict in the diff3 style of git with the base lines between the sides
+func writeConflict(buf *bytes.Buffer, conflict Conflict, currentLabel string, incomingLabel string) {
+	buf.WriteString("<<<<<<< " + currentLabel + "\n")
+	writeConflictLines(buf, conflict.Current)
+	buf.WriteString("||||||| base\n")
+	writeConflictLines(buf, conflict.Base)
+	buf.WriteString("=======\n")
+	writeConflictLines(buf, conflict.Incoming)
+	buf.WriteString(">>>>>>> " + incomingLabel + "\n")
+}
+
+// writeConflictLines writes lines so that the following marker starts on a line of its own
+func writeConflictLines(buf *bytes.Buffer, lines []string) {
+	writeLines(buf, lines)
+	if len(lines) > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteString("\n")
+	}
+}
@@ -0,0 +1,235 @@
+package diff
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		current   string
+		incoming  string
+		want      string
+		conflicts []Conflict
+	}{
+		{
+			name:     "no changes",
+			base:     "a\nb\nc\n",
+			current:  "a\nb\nc\n",
+			incoming: "a\nb\nc\n",
+			want:     "a\nb\nc\n",
+		},
+		{
+			name:     "only current changed",
+			base:     "a\nb\nc\n",
+			current:  "a\nx\nc\n",
+			incoming: "a\nb\nc\n",
+			want:     "a\nx\nc\n",
+		},
+		{
+			name:     "only incoming changed",
+			base:     "a\nb\nc\n",
+			current:  "a\nb\nc\n",
+			incoming: "a\nb\nc\nd\n",
+			want:     "a\nb\nc\nd\n",
+		},
+		{
+			name:     "non-overlapping edits",
+			base:     "a\nb\nc\nd\ne\n",
+			current:  "A\nb\nc\nd\ne\n",
+			incoming: "a\nb\nc\nd\nE\n",
+			want:     "A\nb\nc\nd\nE\n",
+		},
+		{
+			name:     "insertions at different positions",
+			base:     "a\nb\nc\n",
+			current:  "x\na\nb\nc\n",
+			incoming: "a\nb\nc\ny\n",
+			want:     "x\na\nb\nc\ny\n",
+		},
+		{
+			name:     "identical edits",
+			base:     "a\nb\nc\n",
+			current:  "a\nx\nc\n",
+			incoming: "a\nx\nc\n",
+			want:     "a\nx\nc\n",
+		},
+		{
+			name:     "identical deletions",
+			base:     "a\nb\nc\n",
+			current:  "a\nc\n",
+			incoming: "a\nc\n",
+			want:     "a\nc\n",
+		},
+		{
+			name:     "adjacent edits",
+			base:     "a\nb\nc\nd\n",
+			current:  "a\nB\nc\nd\n",
+			incoming: "a\nb\nC\nd\n",
+			want:     "a\n<<<<<<< current\nB\nc\n||||||| base\nb\nc\n=======\nb\nC\n>>>>>>> incoming\nd\n",
+			conflicts: []Conflict{
+				{BaseStart: 2, Base: []string{"b\n", "c\n"}, Current: []string{"B\n", "c\n"}, Incoming: []string{"b\n", "C\n"}},
+			},
+		},
+		{
+			name:     "edits separated by an unchanged line",
+			base:     "a\nb\nc\nd\n",
+			current:  "A\nb\nc\nd\n",
+			incoming: "a\nb\nC\nd\n",
+			want:     "A\nb\nC\nd\n",
+		},
+		{
+			name:     "trailing newline added next to edit",
+			base:     "a\nb",
+			current:  "a\nb\n",
+			incoming: "A\nb",
+			want:     "<<<<<<< current\na\nb\n||||||| base\na\nb\n=======\nA\nb\n>>>>>>> incoming\n",
+			conflicts: []Conflict{
+				{BaseStart: 1, Base: []string{"a\n", "b"}, Current: []string{"a\n", "b\n"}, Incoming: []string{"A\n", "b"}},
+			},
+		},
+		{
+			name:     "different edits of the same line",
+			base:     "a\nb\nc\n",
+			current:  "a\nx\nc\n",
+			incoming: "a\ny\nc\n",
+			want:     "a\n<<<<<<< current\nx\n||||||| base\nb\n=======\ny\n>>>>>>> incoming\nc\n",
+			conflicts: []Conflict{
+				{BaseStart: 2, Base: []string{"b\n"}, Current: []string{"x\n"}, Incoming: []string{"y\n"}},
+			},
+		},
+		{
+			name:     "deletion against edit",
+			base:     "a\nb\nc\n",
+			current:  "a\nc\n",
+			incoming: "a\nx\nc\n",
+			want:     "a\n<<<<<<< current\n||||||| base\nb\n=======\nx\n>>>>>>> incoming\nc\n",
+			conflicts: []Conflict{
+				{BaseStart: 2, Base: []string{"b\n"}, Current: []string{}, Incoming: []string{"x\n"}},
+			},
+		},
+		{
+			name:     "edit against deletion",
+			base:     "a\nb\nc\n",
+			current:  "a\nx\nc\n",
+			incoming: "a\nc\n",
+			want:     "a\n<<<<<<< current\nx\n||||||| base\nb\n=======\n>>>>>>> incoming\nc\n",
+			conflicts: []Conflict{
+				{BaseStart: 2, Base: []string{"b\n"}, Current: []string{"x\n"}, Incoming: []string{}},
+			},
+		},
+		{
+			name:     "deletion next to unrelated edit",
+			base:     "a\nb\nc\nd\ne\n",
+			current:  "a\nc\nd\ne\n",
+			incoming: "a\nb\nc\nd\nE\n",
+			want:     "a\nc\nd\nE\n",
+		},
+		{
+			name:     "conflict at start",
+			base:     "a\nb\nc\n",
+			current:  "x\nb\nc\n",
+			incoming: "y\nb\nc\n",
+			want:     "<<<<<<< current\nx\n||||||| base\na\n=======\ny\n>>>>>>> incoming\nb\nc\n",
+			conflicts: []Conflict{
+				{BaseStart: 1, Base: []string{"a\n"}, Current: []string{"x\n"}, Incoming: []string{"y\n"}},
+			},
+		},
+		{
+			name:     "conflict at end",
+			base:     "a\nb\nc\n",
+			current:  "a\nb\nx\n",
+			incoming: "a\nb\ny\n",
+			want:     "a\nb\n<<<<<<< current\nx\n||||||| base\nc\n=======\ny\n>>>>>>> incoming\n",
+			conflicts: []Conflict{
+				{BaseStart: 3, Base: []string{"c\n"}, Current: []string{"x\n"}, Incoming: []string{"y\n"}},
+			},
+		},
+		{
+			name:     "different insertions at the same position",
+			base:     "a\nb\n",
+			current:  "a\nx\nb\n",
+			incoming: "a\ny\nb\n",
+			want:     "a\n<<<<<<< current\nx\n||||||| base\n=======\ny\n>>>>>>> incoming\nb\n",
+			conflicts: []Conflict{
+				{BaseStart: 2, Base: []string{}, Current: []string{"x\n"}, Incoming: []string{"y\n"}},
+			},
+		},
+		{
+			name:     "empty base",
+			base:     "",
+			current:  "x\n",
+			incoming: "y\n",
+			want:     "<<<<<<< current\nx\n||||||| base\n=======\ny\n>>>>>>> incoming\n",
+			conflicts: []Conflict{
+				{BaseStart: 1, Base: []string{}, Current: []string{"x\n"}, Incoming: []string{"y\n"}},
+			},
+		},
+		{
+			name:     "missing trailing newline kept",
+			base:     "a\nb\nc",
+			current:  "A\nb\nc",
+			incoming: "a\nb\nc",
+			want:     "A\nb\nc",
+		},
+		{
+			name:     "trailing newline added on one side",
+			base:     "a\nb\nc",
+			current:  "a\nb\nc\n",
+			incoming: "A\nb\nc",
+			want:     "A\nb\nc\n",
+		},
+		{
+			name:     "conflict without trailing newline",
+			base:     "a\nb",
+			current:  "a\nx",
+			incoming: "a\ny",
+			want:     "a\n<<<<<<< current\nx\n||||||| base\nb\n=======\ny\n>>>>>>> incoming\n",
+			conflicts: []Conflict{
+				{BaseStart: 2, Base: []string{"b"}, Current: []string{"x"}, Incoming: []string{"y"}},
+			},
+		},
+		{
+			name:     "crlf edits",
+			base:     "a\r\nb\r\nc\r\n",
+			current:  "A\r\nb\r\nc\r\n",
+			incoming: "a\r\nb\r\nC\r\n",
+			want:     "A\r\nb\r\nC\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Merge([]byte(tt.base), []byte(tt.current), []byte(tt.incoming), "current", "incoming")
+			if string(result.Content) != tt.want {
+				t.Errorf("content %q, want %q", result.Content, tt.want)
+			}
+			if result.Clean() != (len(tt.conflicts) == 0) {
+				t.Errorf("Clean() = %t with %d conflicts", result.Clean(), len(result.Conflicts))
+			}
+			if !slices.EqualFunc(result.Conflicts, tt.conflicts, equalConflicts) {
+				t.Errorf("conflicts %+v, want %+v", result.Conflicts, tt.conflicts)
+			}
+		})
+	}
+}
+
+// TestMergeSymmetric checks that clean merges do not depend on the order of the sides
+func TestMergeSymmetric(t *testing.T) {
+	base := "a\nb\nc\nd\ne\nf\n"
+	current := "a\nB\nc\nd\ne\nf\ng\n"
+	incoming := "a\nb\nc\nD\ne\nf\n"
+	forward := Merge([]byte(base), []byte(current), []byte(incoming), "current", "incoming")
+	backward := Merge([]byte(base), []byte(incoming), []byte(current), "incoming", "current")
+	if !forward.Clean() || !backward.Clean() {
+		t.Fatalf("merges have conflicts: %+v, %+v", forward.Conflicts, backward.Conflicts)
+	}
+	if string(forward.Content) != string(backward.Content) {
+		t.Errorf("merges differ: %q, %q", forward.Content, backward.Content)
+	}
+}
+
+func equalConflicts(a Conflict, b Conflict) bool {
+	return a.BaseStart == b.BaseStart && slices.Equal(a.Base, b.Base) && slices.Equal(a.Current, b.Current) && slices.Equal(a.Incoming, b.Incoming)
+}
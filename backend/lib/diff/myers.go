@@ -0,0 +1,203 @@
+// Package diff implements a line based diff using Myers' algorithm together with
+// the unified and normal diff formats.
+package diff
+
+import "bytes"
+
+type EditKind int
+
+const (
+	Equal EditKind = iota
+	Delete
+	Insert
+)
+
+// Edit is a single step to transform the old lines into the new lines. OldIndex and NewIndex
+// are 0-based positions in the old and new lines at which the edit happens.
+type Edit struct {
+	Kind     EditKind
+	OldIndex int
+	NewIndex int
+}
+
+// SplitLines splits content into lines. Every line keeps its line break, only the last line
+// may miss it.
+func SplitLines(content []byte) []string {
+	lines := make([]string, 0, bytes.Count(content, []byte("\n"))+1)
+	for len(content) > 0 {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			lines = append(lines, string(content))
+			break
+		}
+		lines = append(lines, string(content[:i+1]))
+		content = content[i+1:]
+	}
+	return lines
+}
+
+// Compute returns the shortest edit script transforming a into b
+func Compute(a []string, b []string) []Edit {
+	ids := make(map[string]int)
+	intern := func(lines []string) []int {
+		res := make([]int, len(lines))
+		for i, l := range lines {
+			id, ok := ids[l]
+			if !ok {
+				id = len(ids)
+				ids[l] = id
+			}
+			res[i] = id
+		}
+		return res
+	}
+	d := differ{
+		a:       intern(a),
+		b:       intern(b),
+		removed: make([]bool, len(a)),
+		added:   make([]bool, len(b)),
+	}
+	d.compare(0, len(a), 0, len(b))
+
+	edits := make([]Edit, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && d.removed[i]:
+			edits = append(edits, Edit{Kind: Delete, OldIndex: i, NewIndex: j})
+			i++
+		case j < len(b) && d.added[j]:
+			edits = append(edits, Edit{Kind: Insert, OldIndex: i, NewIndex: j})
+			j++
+		default:
+			edits = append(edits, Edit{Kind: Equal, OldIndex: i, NewIndex: j})
+			i++
+			j++
+		}
+	}
+	return edits
+}
+
+type differ struct {
+	a       []int
+	b       []int
+	removed []bool
+	added   []bool
+}
+
+// compare marks the removed lines of a[aLo:aHi] and the added lines of b[bLo:bHi]
+func (d *differ) compare(aLo int, aHi int, bLo int, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+	if aLo == aHi {
+		for j := bLo; j < bHi; j++ {
+			d.added[j] = true
+		}
+		return
+	}
+	if bLo == bHi {
+		for i := aLo; i < aHi; i++ {
+			d.removed[i] = true
+		}
+		return
+	}
+	x, y, ok := d.bisect(aLo, aHi, bLo, bHi)
+	if !ok {
+		for i := aLo; i < aHi; i++ {
+			d.removed[i] = true
+		}
+		for j := bLo; j < bHi; j++ {
+			d.added[j] = true
+		}
+		return
+	}
+	d.compare(aLo, x, bLo, y)
+	d.compare(x, aHi, y, bHi)
+}
+
+// bisect finds the middle snake of the shortest edit path in linear space and returns the
+// point at which the problem can be split into two smaller ones.
+func (d *differ) bisect(aLo int, aHi int, bLo int, bHi int) (int, int, bool) {
+	n, m := aHi-aLo, bHi-bLo
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	length := 2*maxD + 2
+	forward := make([]int, length)
+	backward := make([]int, length)
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+	delta := n - m
+	// If the total number of lines is odd, the forward path will collide with the reverse path
+	front := delta%2 != 0
+	k1Start, k1End, k2Start, k2End := 0, 0, 0, 0
+	for step := 0; step < maxD; step++ {
+		for k1 := -step + k1Start; k1 <= step-k1End; k1 += 2 {
+			k1Offset := offset + k1
+			var x1 int
+			if k1 == -step || (k1 != step && forward[k1Offset-1] < forward[k1Offset+1]) {
+				x1 = forward[k1Offset+1]
+			} else {
+				x1 = forward[k1Offset-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && d.a[aLo+x1] == d.b[bLo+y1] {
+				x1++
+				y1++
+			}
+			forward[k1Offset] = x1
+			if x1 > n {
+				k1End += 2
+			} else if y1 > m {
+				k1Start += 2
+			} else if front {
+				k2Offset := offset + delta - k1
+				if k2Offset >= 0 && k2Offset < length && backward[k2Offset] != -1 {
+					x2 := n - backward[k2Offset]
+					if x1 >= x2 {
+						return aLo + x1, bLo + y1, true
+					}
+				}
+			}
+		}
+		for k2 := -step + k2Start; k2 <= step-k2End; k2 += 2 {
+			k2Offset := offset + k2
+			var x2 int
+			if k2 == -step || (k2 != step && backward[k2Offset-1] < backward[k2Offset+1]) {
+				x2 = backward[k2Offset+1]
+			} else {
+				x2 = backward[k2Offset-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && d.a[aHi-x2-1] == d.b[bHi-y2-1] {
+				x2++
+				y2++
+			}
+			backward[k2Offset] = x2
+			if x2 > n {
+				k2End += 2
+			} else if y2 > m {
+				k2Start += 2
+			} else if !front {
+				k1Offset := offset + delta - k2
+				if k1Offset >= 0 && k1Offset < length && forward[k1Offset] != -1 {
+					x1 := forward[k1Offset]
+					y1 := offset + x1 - k1Offset
+					if x1 >= n-x2 {
+						return aLo + x1, bLo + y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,139 @@
+package diff
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const noNewlineMarker = "\\ No newline at end of file\n"
+
+var ErrInvalidPatch = errors.New("invalid patch")
+
+var normalCommandPattern = regexp.MustCompile(`^(\d+)(?:,(\d+))?([acd])(\d+)(?:,(\d+))?$`)
+
+type normalCommand struct {
+	kind     byte
+	oldFrom  int
+	oldTo    int
+	newLines []string
+}
+
+// ApplyNormal applies a diff in the normal format of diff(1) to content. Only diffs stored in format version 1 use it.
+func ApplyNormal(content []byte, patch []byte) ([]byte, error) {
+	commands, err := parseNormal(patch)
+	if err != nil {
+		return nil, err
+	}
+	lines := SplitLines(content)
+	var out bytes.Buffer
+	next := 0
+	for _, c := range commands {
+		// Lines up to oldFrom are unchanged, for additions oldFrom is the line after which is added
+		keepUntil := c.oldFrom
+		if c.kind != 'a' {
+			keepUntil = c.oldFrom - 1
+		}
+		if keepUntil < next || c.oldTo > len(lines) {
+			return nil, fmt.Errorf("%w: command for lines %d-%d does not fit content with %d lines", ErrInvalidPatch, c.oldFrom, c.oldTo, len(lines))
+		}
+		for _, l := range lines[next:keepUntil] {
+			out.WriteString(l)
+		}
+		for _, l := range c.newLines {
+			out.WriteString(l)
+		}
+		next = keepUntil
+		if c.kind != 'a' {
+			next = c.oldTo
+		}
+	}
+	for _, l := range lines[next:] {
+		out.WriteString(l)
+	}
+	return out.Bytes(), nil
+}
+
+func parseNormal(patch []byte) ([]normalCommand, error) {
+	commands := make([]normalCommand, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(patch))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	scanner.Split(scanLines)
+	var current *normalCommand
+	// Lines of the command that still have to be read
+	oldRemaining, newRemaining := 0, 0
+	separatorPending := false
+	var lastLines *[]string
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		text := scanner.Text()
+		switch {
+		case text == strings.TrimSuffix(noNewlineMarker, "\n"):
+			if lastLines == nil || len(*lastLines) == 0 {
+				return nil, fmt.Errorf("%w: line %d: missing line before %q", ErrInvalidPatch, lineNumber, text)
+			}
+			l := *lastLines
+			l[len(l)-1] = strings.TrimSuffix(l[len(l)-1], "\n")
+		case current != nil && oldRemaining > 0 && strings.HasPrefix(text, "< "):
+			oldRemaining--
+			// Removed lines are not needed to apply the patch, only their count
+			removed := []string{text[2:] + "\n"}
+			lastLines = &removed
+		case current != nil && oldRemaining == 0 && separatorPending && text == "---":
+			separatorPending = false
+			lastLines = nil
+		case current != nil && oldRemaining == 0 && !separatorPending && newRemaining > 0 && strings.HasPrefix(text, "> "):
+			newRemaining--
+			current.newLines = append(current.newLines, text[2:]+"\n")
+			lastLines = &current.newLines
+		default:
+			if current != nil && (oldRemaining > 0 || newRemaining > 0 || separatorPending) {
+				return nil, fmt.Errorf("%w: line %d: unexpected %q", ErrInvalidPatch, lineNumber, text)
+			}
+			match := normalCommandPattern.FindStringSubmatch(text)
+			if match == nil {
+				return nil, fmt.Errorf("%w: line %d: unknown command %q", ErrInvalidPatch, lineNumber, text)
+			}
+			oldFrom, oldTo := parseNormalRange(match[1], match[2])
+			newFrom, newTo := parseNormalRange(match[4], match[5])
+			if oldTo < oldFrom || newTo < newFrom || (len(commands) > 0 && oldFrom <= commands[len(commands)-1].oldTo && match[3][0] != 'a') {
+				return nil, fmt.Errorf("%w: line %d: invalid range in %q", ErrInvalidPatch, lineNumber, text)
+			}
+			commands = append(commands, normalCommand{kind: match[3][0], oldFrom: oldFrom, oldTo: oldTo})
+			current = &commands[len(commands)-1]
+			lastLines = nil
+			switch current.kind {
+			case 'a':
+				current.oldTo = oldFrom
+				newRemaining = newTo - newFrom + 1
+			case 'd':
+				oldRemaining = oldTo - oldFrom + 1
+			case 'c':
+				oldRemaining = oldTo - oldFrom + 1
+				newRemaining = newTo - newFrom + 1
+				separatorPending = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil && (oldRemaining > 0 || newRemaining > 0 || separatorPending) {
+		return nil, fmt.Errorf("%w: last command is incomplete", ErrInvalidPatch)
+	}
+	return commands, nil
+}
+
+func parseNormalRange(from string, to string) (int, int) {
+	start, _ := strconv.Atoi(from)
+	if len(to) == 0 {
+		return start, start
+	}
+	end, _ := strconv.Atoi(to)
+	return start, end
+}
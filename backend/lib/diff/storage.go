@@ -0,0 +1,98 @@
+package diff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Stored diffs are reverse deltas: applied to the content of a version they restore the content of
+// its previous version. Since version 2 a stored diff starts with a format line followed by a unified
+// diff with file headers naming both versions:
+//
+//	# bongo-diff v2
+//	--- <id of the version>
+//	+++ <id of the previous version>
+//	@@ -1,4 +1,3 @@
+//	 unchanged line
+//	-line added by the version
+//	 unchanged line
+//	...
+//
+// A diff without hunks means the version did not change the content. Diffs without a format line
+// were written by earlier releases in the normal format of diff(1) and are read as version 1.
+const (
+	FormatVersion       = 2
+	storedContextLines  = 3
+	legacyFormatVersion = 1
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported diff format")
+	ErrRoundTrip         = errors.New("diff does not reproduce content")
+	formatLinePattern    = regexp.MustCompile(`^# bongo-diff v(\d+)\n`)
+)
+
+// Encode creates the stored diff restoring previous from content. The diff is applied before it is
+// returned, so every stored diff is guaranteed to reproduce previous byte for byte.
+func Encode(versionLabel string, previousLabel string, content []byte, previous []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# bongo-diff v%d\n", FormatVersion)
+	hunks := UnifiedHunks(content, previous, storedContextLines)
+	buf.WriteString(FormatUnified(versionLabel, previousLabel, hunks))
+	stored := buf.Bytes()
+	restored, err := Apply(content, stored)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRoundTrip, err)
+	}
+	if !bytes.Equal(restored, previous) {
+		return nil, ErrRoundTrip
+	}
+	return stored, nil
+}
+
+// Apply applies a stored diff of any supported format version to content
+func Apply(content []byte, stored []byte) ([]byte, error) {
+	version, body, err := formatVersion(stored)
+	if err != nil {
+		return nil, err
+	}
+	switch version {
+	case legacyFormatVersion:
+		return ApplyNormal(content, body)
+	case FormatVersion:
+		hunks, err := ParseUnified(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+		}
+		return ApplyUnified(content, hunks)
+	default:
+		return nil, fmt.Errorf("%w: version %d", ErrUnsupportedFormat, version)
+	}
+}
+
+// StoredHunks returns the hunks of a stored diff of any supported format version
+func StoredHunks(stored []byte) ([]Hunk, error) {
+	version, body, err := formatVersion(stored)
+	if err != nil {
+		return nil, err
+	}
+	if version != FormatVersion {
+		return nil, fmt.Errorf("%w: hunks of version %d", ErrUnsupportedFormat, version)
+	}
+	return ParseUnified(string(body))
+}
+
+func formatVersion(stored []byte) (int, []byte, error) {
+	match := formatLinePattern.FindSubmatch(stored)
+	if match == nil {
+		return legacyFormatVersion, stored, nil
+	}
+	version, err := strconv.Atoi(string(match[1]))
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %w", ErrUnsupportedFormat, err)
+	}
+	return version, stored[len(match[0]):], nil
+}
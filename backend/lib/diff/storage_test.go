@@ -0,0 +1,146 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		previous string
+		// markers is the number of no newline markers expected in the stored diff
+		markers int
+	}{
+		{name: "unchanged", content: "a\nb\nc\n", previous: "a\nb\nc\n"},
+		{name: "both empty", content: "", previous: ""},
+		{name: "content empty", content: "", previous: "a\nb\n"},
+		{name: "previous empty", content: "a\nb\n", previous: ""},
+		{name: "line added", content: "a\nb\nc\n", previous: "a\nc\n"},
+		{name: "line removed", content: "a\nc\n", previous: "a\nb\nc\n"},
+		{name: "lines changed", content: "a\nx\ny\nd\n", previous: "a\nb\nc\nd\n"},
+		{name: "distant changes", content: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", previous: "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n"},
+		{name: "last line changed", content: "a\nb\nc\n", previous: "a\nb\nd\n"},
+		{name: "last line changed without newline", content: "a\nb\nc", previous: "a\nb\nd", markers: 2},
+		{name: "newline added at end", content: "a\nb\n", previous: "a\nb", markers: 1},
+		{name: "newline removed at end", content: "a\nb", previous: "a\nb\n", markers: 1},
+		{name: "no newline on both sides unchanged end", content: "x\nb", previous: "a\nb", markers: 1},
+		{name: "single line without newline", content: "a", previous: "b", markers: 2},
+		{name: "empty to no newline", content: "", previous: "a", markers: 1},
+		{name: "crlf", content: "a\r\nb\r\nc\r\n", previous: "a\r\nx\r\nc\r\n"},
+		{name: "crlf to lf", content: "a\r\nb\r\n", previous: "a\nb\n"},
+		{name: "crlf without newline at end", content: "a\r\nb", previous: "a\r\nc", markers: 2},
+		{name: "lone carriage return", content: "a\rb\n", previous: "a\nb\n"},
+		{name: "blank lines", content: "\n\n\n", previous: "\n\n"},
+		{name: "lines looking like diff syntax", content: "--- a\n+++ b\n@@ -1 +1 @@\n", previous: "\\ No newline at end of file\n# bongo-diff v2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored, err := Encode("version", "previous", []byte(tt.content), []byte(tt.previous))
+			if err != nil {
+				t.Fatalf("Encode: %s", err)
+			}
+			restored, err := Apply([]byte(tt.content), stored)
+			if err != nil {
+				t.Fatalf("Apply: %s\n%s", err, stored)
+			}
+			if !bytes.Equal(restored, []byte(tt.previous)) {
+				t.Fatalf("restored %q, want %q\n%s", restored, tt.previous, stored)
+			}
+			if markers := strings.Count("\n"+string(stored), "\n"+noNewlineMarker); markers != tt.markers {
+				t.Errorf("stored diff has %d no newline markers, want %d\n%s", markers, tt.markers, stored)
+			}
+		})
+	}
+}
+
+func TestEncodeFormat(t *testing.T) {
+	stored, err := Encode("v2", "v1", []byte("a\nb\n"), []byte("a\nc\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# bongo-diff v2\n--- v2\n+++ v1\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n"
+	if string(stored) != want {
+		t.Errorf("stored %q, want %q", stored, want)
+	}
+}
+
+func TestApplyLegacyNormal(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		patch   string
+		want    string
+	}{
+		{name: "empty patch", content: "a\nb\n", patch: "", want: "a\nb\n"},
+		{name: "add", content: "a\nc\n", patch: "1a2\n> b\n", want: "a\nb\nc\n"},
+		{name: "add at start", content: "b\n", patch: "0a1\n> a\n", want: "a\nb\n"},
+		{name: "add to empty", content: "", patch: "0a1,2\n> a\n> b\n", want: "a\nb\n"},
+		{name: "delete", content: "a\nb\nc\n", patch: "2d1\n< b\n", want: "a\nc\n"},
+		{name: "delete all", content: "a\nb\n", patch: "1,2d0\n< a\n< b\n", want: ""},
+		{name: "change", content: "a\nb\nc\n", patch: "2c2\n< b\n---\n> x\n", want: "a\nx\nc\n"},
+		{name: "change range", content: "a\nb\nc\nd\n", patch: "2,3c2\n< b\n< c\n---\n> x\n", want: "a\nx\nd\n"},
+		{name: "several commands", content: "a\nb\nc\nd\ne\n", patch: "1d0\n< a\n3c2\n< c\n---\n> x\n5a5\n> f\n", want: "b\nx\nd\ne\nf\n"},
+		{name: "last line", content: "a\nb\n", patch: "2c2\n< b\n---\n> c\n", want: "a\nc\n"},
+		{
+			name:    "no newline in new content",
+			content: "a\nb\n",
+			patch:   "2c2\n< b\n---\n> b\n\\ No newline at end of file\n",
+			want:    "a\nb",
+		},
+		{
+			name:    "no newline in old content",
+			content: "a\nb",
+			patch:   "2c2\n< b\n\\ No newline at end of file\n---\n> b\n",
+			want:    "a\nb\n",
+		},
+		{
+			name:    "no newline on both sides",
+			content: "a\nb",
+			patch:   "2c2\n< b\n\\ No newline at end of file\n---\n> c\n\\ No newline at end of file\n",
+			want:    "a\nc",
+		},
+		{name: "crlf", content: "a\r\nb\r\n", patch: "2c2\n< b\r\n---\n> c\r\n", want: "a\r\nc\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyNormal([]byte(tt.content), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("ApplyNormal: %s", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ApplyNormal = %q, want %q", got, tt.want)
+			}
+			// Stored diffs without a format line are read as version 1
+			got, err = Apply([]byte(tt.content), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Apply: %s", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Apply = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyLegacyNormalInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		patch   string
+	}{
+		{name: "unknown command", content: "a\n", patch: "x\n"},
+		{name: "incomplete command", content: "a\nb\n", patch: "2c2\n< b\n"},
+		{name: "range beyond content", content: "a\n", patch: "3d2\n< c\n"},
+		{name: "marker without line", content: "a\n", patch: "\\ No newline at end of file\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ApplyNormal([]byte(tt.content), []byte(tt.patch)); err == nil {
+				t.Error("ApplyNormal succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package diff
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type LineType string
+
+const (
+	Context LineType = "context"
+	Added   LineType = "added"
+	Removed LineType = "removed"
+)
+
+// Line is a single line of a hunk. OldLine and NewLine are 1-based line numbers and 0 if the
+// line does not exist on that side.
+type Line struct {
+	Type    LineType `json:"type"`
+	Content string   `json:"content"`
+	OldLine int      `json:"oldLine,omitempty"`
+	NewLine int      `json:"newLine,omitempty"`
+	// NoNewline is set if the line is the last one of its file and not terminated by a line break
+	NoNewline bool `json:"noNewline,omitempty"`
+}
+
+type Hunk struct {
+	OldStart int    `json:"oldStart"`
+	OldLines int    `json:"oldLines"`
+	NewStart int    `json:"newStart"`
+	NewLines int    `json:"newLines"`
+	Lines    []Line `json:"lines"`
+}
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ParseUnified parses the hunks of a diff in unified format. File headers are skipped.
+func ParseUnified(unified string) ([]Hunk, error) {
+	hunks := make([]Hunk, 0)
+	scanner := bufio.NewScanner(strings.NewReader(unified))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	scanner.Split(scanLines)
+	var current *Hunk
+	oldLine, newLine := 0, 0
+	remainingOld, remainingNew := 0, 0
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		text := scanner.Text()
+		if current != nil && remainingOld == 0 && remainingNew == 0 && !strings.HasPrefix(text, "\\") {
+			current = nil
+		}
+		if current == nil {
+			match := hunkHeaderPattern.FindStringSubmatch(text)
+			if match == nil {
+				// File headers and other lines between hunks
+				continue
+			}
+			hunk, err := parseHunkHeader(match)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			hunks = append(hunks, hunk)
+			current = &hunks[len(hunks)-1]
+			oldLine, newLine = hunk.OldStart, hunk.NewStart
+			remainingOld, remainingNew = hunk.OldLines, hunk.NewLines
+			continue
+		}
+		if len(text) == 0 {
+			return nil, fmt.Errorf("line %d: empty line in hunk", lineNumber)
+		}
+		switch text[0] {
+		case ' ':
+			if remainingOld == 0 || remainingNew == 0 {
+				return nil, fmt.Errorf("line %d: hunk contains more lines than announced", lineNumber)
+			}
+			current.Lines = append(current.Lines, Line{Type: Context, Content: text[1:], OldLine: oldLine, NewLine: newLine})
+			oldLine++
+			newLine++
+			remainingOld--
+			remainingNew--
+		case '-':
+			if remainingOld == 0 {
+				return nil, fmt.Errorf("line %d: hunk contains more removed lines than announced", lineNumber)
+			}
+			current.Lines = append(current.Lines, Line{Type: Removed, Content: text[1:], OldLine: oldLine})
+			oldLine++
+			remainingOld--
+		case '+':
+			if remainingNew == 0 {
+				return nil, fmt.Errorf("line %d: hunk contains more added lines than announced", lineNumber)
+			}
+			current.Lines = append(current.Lines, Line{Type: Added, Content: text[1:], NewLine: newLine})
+			newLine++
+			remainingNew--
+		case '\\':
+			if len(current.Lines) == 0 {
+				return nil, fmt.Errorf("line %d: missing line before %q", lineNumber, text)
+			}
+			current.Lines[len(current.Lines)-1].NoNewline = true
+		default:
+			return nil, fmt.Errorf("line %d: unexpected line in hunk: %q", lineNumber, text)
+		}
+	}
+	if current != nil && (remainingOld > 0 || remainingNew > 0) {
+		return nil, fmt.Errorf("hunk at %d,%d is incomplete", current.OldStart, current.NewStart)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return hunks, nil
+}
+
+func parseHunkHeader(match []string) (Hunk, error) {
+	numbers := make([]int, 4)
+	for i, m := range match[1:] {
+		if len(m) == 0 {
+			numbers[i] = 1
+			continue
+		}
+		n, err := strconv.Atoi(m)
+		if err != nil {
+			return Hunk{}, fmt.Errorf("invalid hunk header: %w", err)
+		}
+		numbers[i] = n
+	}
+	return Hunk{
+		OldStart: numbers[0],
+		OldLines: numbers[1],
+		NewStart: numbers[2],
+		NewLines: numbers[3],
+		Lines:    make([]Line, 0, numbers[1]+numbers[3]),
+	}, nil
+}
+
+// scanLines splits like bufio.ScanLines but keeps carriage returns as they are part of the content
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// ApplyUnified applies hunks to content. Context and removed lines have to match exactly.
+func ApplyUnified(content []byte, hunks []Hunk) ([]byte, error) {
+	lines := SplitLines(content)
+	var out bytes.Buffer
+	next := 0
+	for _, h := range hunks {
+		// Hunks without old lines start after the line given in the header
+		start := h.OldStart - 1
+		if h.OldLines == 0 {
+			start = h.OldStart
+		}
+		if start < next || start > len(lines) {
+			return nil, fmt.Errorf("%w: hunk at line %d does not fit content with %d lines", ErrInvalidPatch, h.OldStart, len(lines))
+		}
+		for _, l := range lines[next:start] {
+			out.WriteString(l)
+		}
+		next = start
+		for _, l := range h.Lines {
+			text := l.Content + "\n"
+			if l.NoNewline {
+				text = l.Content
+			}
+			if l.Type == Added {
+				out.WriteString(text)
+				continue
+			}
+			if next >= len(lines) || lines[next] != text {
+				return nil, fmt.Errorf("%w: line %d does not match hunk at line %d", ErrInvalidPatch, next+1, h.OldStart)
+			}
+			if l.Type == Context {
+				out.WriteString(text)
+			}
+			next++
+		}
+	}
+	for _, l := range lines[next:] {
+		out.WriteString(l)
+	}
+	return out.Bytes(), nil
+}
+
+// UnifiedHunks computes the hunks transforming from into to with the given number of context lines
+func UnifiedHunks(from []byte, to []byte, contextLines int) []Hunk {
+	a, b := SplitLines(from), SplitLines(to)
+	edits := Compute(a, b)
+	hunks := make([]Hunk, 0)
+	previousEnd := 0
+	for start := 0; start < len(edits); {
+		// Skip to the next change
+		for start < len(edits) && edits[start].Kind == Equal {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		// Extend the hunk as long as changes are close enough to share context
+		end := start
+		for i := start; i < len(edits); i++ {
+			if edits[i].Kind != Equal {
+				end = i + 1
+				continue
+			}
+			if i-end >= 2*contextLines {
+				break
+			}
+		}
+		// Context must not reach into the previous hunk, the gap to the next one is larger than the context
+		from := max(start-contextLines, previousEnd)
+		to := min(end+contextLines, len(edits))
+		hunks = append(hunks, buildHunk(a, b, edits[from:to]))
+		start, previousEnd = to, to
+	}
+	return hunks
+}
+
+func buildHunk(a []string, b []string, edits []Edit) Hunk {
+	first := edits[0]
+	hunk := Hunk{
+		OldStart: first.OldIndex + 1,
+		NewStart: first.NewIndex + 1,
+		Lines:    make([]Line, 0, len(edits)),
+	}
+	for _, e := range edits {
+		switch e.Kind {
+		case Equal:
+			content, noNewline := trimLineBreak(a[e.OldIndex])
+			hunk.Lines = append(hunk.Lines, Line{Type: Context, Content: content, OldLine: e.OldIndex + 1, NewLine: e.NewIndex + 1, NoNewline: noNewline})
+			hunk.OldLines++
+			hunk.NewLines++
+		case Delete:
+			content, noNewline := trimLineBreak(a[e.OldIndex])
+			hunk.Lines = append(hunk.Lines, Line{Type: Removed, Content: content, OldLine: e.OldIndex + 1, NoNewline: noNewline})
+			hunk.OldLines++
+		case Insert:
+			content, noNewline := trimLineBreak(b[e.NewIndex])
+			hunk.Lines = append(hunk.Lines, Line{Type: Added, Content: content, NewLine: e.NewIndex + 1, NoNewline: noNewline})
+			hunk.NewLines++
+		}
+	}
+	// Empty ranges start at the line before them
+	if hunk.OldLines == 0 {
+		hunk.OldStart--
+	}
+	if hunk.NewLines == 0 {
+		hunk.NewStart--
+	}
+	return hunk
+}
+
+func trimLineBreak(line string) (string, bool) {
+	if strings.HasSuffix(line, "\n") {
+		return line[:len(line)-1], false
+	}
+	return line, true
+}
+
+// FormatUnified writes hunks as unified diff. Without hunks the result is empty.
+func FormatUnified(fromLabel string, toLabel string, hunks []Hunk) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromLabel, toLabel)
+	for _, h := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", formatRange(h.OldStart, h.OldLines), formatRange(h.NewStart, h.NewLines))
+		for _, l := range h.Lines {
+			switch l.Type {
+			case Context:
+				sb.WriteByte(' ')
+			case Removed:
+				sb.WriteByte('-')
+			case Added:
+				sb.WriteByte('+')
+			}
+			sb.WriteString(l.Content)
+			sb.WriteByte('\n')
+			if l.NoNewline {
+				sb.WriteString(noNewlineMarker)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func formatRange(start int, lines int) string {
+	if lines == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
@@ -0,0 +1,52 @@
+// Package markdown renders notes written in GitHub Flavored Markdown to sanitized HTML.
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+)
+
+// RendererVersion changes whenever the output of Render changes, so cached results can be told apart
+const RendererVersion = 1
+
+var (
+	renderer = goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			highlighting.NewHighlighting(
+				highlighting.WithStyle("github"),
+				// Inline styles keep the rendered HTML usable without a separate stylesheet
+				highlighting.WithFormatOptions(chromahtml.WithClasses(false)),
+			),
+		),
+	)
+	policy = newPolicy()
+)
+
+// newPolicy allows the HTML generated from Markdown. Raw HTML is already dropped by the renderer,
+// the policy guards against anything slipping through links or attributes.
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// Task lists
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^$|^checked$|^disabled$`)).OnElements("input")
+	// Syntax highlighting
+	p.AllowStyles("color", "background-color", "font-weight", "font-style", "text-decoration").OnElements("span", "pre")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	return p
+}
+
+// Render converts Markdown to sanitized HTML
+func Render(source []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert(source, &buf); err != nil {
+		return nil, err
+	}
+	return policy.SanitizeBytes(buf.Bytes()), nil
+}
@@ -0,0 +1,139 @@
+package markdown
+
+import (
+	"bytes"
+	"strings"
+)
+
+// maxWikiLinkLength bounds the text between the brackets of a wiki link
+const maxWikiLinkLength = 256
+
+// WikiLink is a link to another note written as [[target]] or [[target|label]]
+type WikiLink struct {
+	Target string
+	Label  string
+	// Start and End are the byte offsets of the link including its brackets
+	Start int
+	End   int
+}
+
+// WikiLinks returns the wiki links of a note in order of appearance. Links in fenced code blocks and code spans are ignored.
+func WikiLinks(source []byte) []WikiLink {
+	var links []WikiLink
+	scanWikiLinks(source, func(l WikiLink) {
+		links = append(links, l)
+	})
+	return links
+}
+
+// ReplaceWikiLinks replaces the links for which replace returns a new target, labels are kept
+func ReplaceWikiLinks(source []byte, replace func(WikiLink) (string, bool)) []byte {
+	var b bytes.Buffer
+	last := 0
+	scanWikiLinks(source, func(l WikiLink) {
+		target, ok := replace(l)
+		if !ok {
+			return
+		}
+		b.Write(source[last:l.Start])
+		b.WriteString("[[" + target)
+		if len(l.Label) > 0 {
+			b.WriteString("|" + l.Label)
+		}
+		b.WriteString("]]")
+		last = l.End
+	})
+	b.Write(source[last:])
+	return b.Bytes()
+}
+
+func scanWikiLinks(source []byte, fn func(WikiLink)) {
+	var fence string
+	offset := 0
+	for _, line := range bytes.SplitAfter(source, []byte("\n")) {
+		lineStart := offset
+		offset += len(line)
+		if marker, bare := codeFence(line); len(marker) > 0 {
+			if len(fence) == 0 {
+				fence = marker
+				continue
+			}
+			// Fences with an info string only open blocks
+			if bare && marker[0] == fence[0] && len(marker) >= len(fence) {
+				fence = ""
+				continue
+			}
+		}
+		if len(fence) > 0 {
+			continue
+		}
+		scanLine(line, lineStart, fn)
+	}
+}
+
+// scanLine finds the links of a single line outside of code spans
+func scanLine(line []byte, lineStart int, fn func(WikiLink)) {
+	for i := 0; i < len(line); {
+		if line[i] == '`' {
+			run := countRun(line[i:], '`')
+			closing := bytes.Index(line[i+run:], bytes.Repeat([]byte("`"), run))
+			if closing < 0 {
+				i += run
+				continue
+			}
+			i += run + closing + run
+			continue
+		}
+		if !bytes.HasPrefix(line[i:], []byte("[[")) {
+			i++
+			continue
+		}
+		end := bytes.Index(line[i+2:], []byte("]]"))
+		if end < 0 {
+			return
+		}
+		inner := line[i+2 : i+2+end]
+		if bytes.ContainsAny(inner, "[]") || len(inner) > maxWikiLinkLength {
+			i += 2
+			continue
+		}
+		target, label, _ := strings.Cut(string(inner), "|")
+		target = strings.TrimSpace(target)
+		if len(target) > 0 {
+			fn(WikiLink{
+				Target: target,
+				Label:  strings.TrimSpace(label),
+				Start:  lineStart + i,
+				End:    lineStart + i + 2 + end + 2,
+			})
+		}
+		i += 2 + end + 2
+	}
+}
+
+// codeFence returns the fence if a line opens or closes a fenced code block, bare is set if nothing but whitespace
+// follows the fence
+func codeFence(line []byte) (fence string, bare bool) {
+	trimmed := bytes.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) == 0 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return "", false
+	}
+	run := countRun(trimmed, trimmed[0])
+	if run < 3 {
+		return "", false
+	}
+	info := trimmed[run:]
+	// Backticks in the info string would make the line a code span
+	if trimmed[0] == '`' && bytes.IndexByte(info, '`') >= 0 {
+		return "", false
+	}
+	return string(trimmed[:run]), len(bytes.TrimRight(info, " \t\r\n")) == 0
+}
+
+func countRun(b []byte, c byte) int {
+	n := 0
+	for n < len(b) && b[n] == c {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,69 @@
+package markdown
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestWikiLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		targets []string
+	}{
+		{name: "links", source: "[[A]] and [[B|label]]\n[[ C ]]", targets: []string{"A", "B", "C"}},
+		{name: "empty target", source: "[[]] [[ |label]] [[A]]", targets: []string{"A"}},
+		{name: "nested brackets", source: "[[a[b]] [[A]]", targets: []string{"A"}},
+		{name: "backtick fence", source: "```\n[[A]]\n```\n[[B]]\n", targets: []string{"B"}},
+		{name: "tilde fence", source: "~~~\n[[A]]\n~~~\n[[B]]\n", targets: []string{"B"}},
+		{name: "fence with info string", source: "```go\n[[A]]\n```\n[[B]]\n", targets: []string{"B"}},
+		{name: "info string does not close", source: "```\n```go\n[[A]]\n```\n[[B]]\n", targets: []string{"B"}},
+		{name: "tilde info string does not close", source: "~~~\n~~~ text\n[[A]]\n~~~\n[[B]]\n", targets: []string{"B"}},
+		{name: "other fence character does not close", source: "~~~\n```\n[[A]]\n~~~\n[[B]]\n", targets: []string{"B"}},
+		{name: "shorter fence does not close", source: "````\n```\n[[A]]\n````\n[[B]]\n", targets: []string{"B"}},
+		{name: "longer fence closes", source: "```\n[[A]]\n`````\n[[B]]\n", targets: []string{"B"}},
+		{name: "closing fence with trailing spaces", source: "```\n[[A]]\n```  \n[[B]]\n", targets: []string{"B"}},
+		{name: "indented fence", source: "   ```\n[[A]]\n  ```\n[[B]]\n", targets: []string{"B"}},
+		{name: "indented code is no fence", source: "    ```\n[[A]]\n", targets: []string{"A"}},
+		{name: "backticks in info string", source: "``` a`b\n[[A]]\n", targets: []string{"A"}},
+		{name: "unclosed fence", source: "[[A]]\n```\n[[B]]\n", targets: []string{"A"}},
+		{name: "crlf fence", source: "```\r\n[[A]]\r\n```\r\n[[B]]\r\n", targets: []string{"B"}},
+		{name: "code span", source: "`[[A]]` [[B]]", targets: []string{"B"}},
+		{name: "double backtick code span", source: "`` [[A]] ` `` [[B]]", targets: []string{"B"}},
+		{name: "unclosed code span", source: "`[[A]]", targets: []string{"A"}},
+		{name: "code span ends at line", source: "`[[A]]\n[[B]]`", targets: []string{"A", "B"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var targets []string
+			for _, l := range WikiLinks([]byte(tt.source)) {
+				targets = append(targets, l.Target)
+			}
+			if !slices.Equal(targets, tt.targets) {
+				t.Errorf("targets %q, want %q", targets, tt.targets)
+			}
+		})
+	}
+}
+
+func TestWikiLinksOffsets(t *testing.T) {
+	source := "a [[Target | Label]]\n`[[x]]` [[ä]]"
+	want := []WikiLink{
+		{Target: "Target", Label: "Label", Start: 2, End: 20},
+		{Target: "ä", Start: 29, End: 35},
+	}
+	if links := WikiLinks([]byte(source)); !slices.Equal(links, want) {
+		t.Errorf("links %+v, want %+v", links, want)
+	}
+}
+
+func TestReplaceWikiLinks(t *testing.T) {
+	source := "[[Old]] [[old|label]] [[Other]]\n```\n[[Old]]\n```\n"
+	want := "[[New]] [[New|label]] [[Other]]\n```\n[[Old]]\n```\n"
+	got := ReplaceWikiLinks([]byte(source), func(l WikiLink) (string, bool) {
+		return "New", l.Target == "Old" || l.Target == "old"
+	})
+	if string(got) != want {
+		t.Errorf("replaced %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,25 @@
+package ot
+
+import (
+	"unicode/utf8"
+
+	"github.com/bongofriend/bongo-notes/backend/lib/diff"
+)
+
+// FromDiff returns an operation transforming from into to. Changed lines are replaced as a whole.
+func FromDiff(from string, to string) Operation {
+	fromLines := diff.SplitLines([]byte(from))
+	toLines := diff.SplitLines([]byte(to))
+	var op Operation
+	for _, edit := range diff.Compute(fromLines, toLines) {
+		switch edit.Kind {
+		case diff.Equal:
+			op.Retain(utf8.RuneCountInString(fromLines[edit.OldIndex]))
+		case diff.Delete:
+			op.Delete(utf8.RuneCountInString(fromLines[edit.OldIndex]))
+		case diff.Insert:
+			op.Insert(toLines[edit.NewIndex])
+		}
+	}
+	return op
+}
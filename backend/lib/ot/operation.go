@@ -0,0 +1,251 @@
+// Package ot implements operational transformation of plain text documents. Positions and lengths
+// count Unicode code points.
+package ot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+var (
+	ErrLengthMismatch   = errors.New("operation does not match document length")
+	ErrInvalidOperation = errors.New("invalid operation")
+)
+
+// component is a single step of an operation. Exactly one of its fields is set.
+type component struct {
+	retain int
+	insert string
+	delete int
+}
+
+// Operation transforms a document by retaining, inserting and deleting text from its start to its end.
+// It is encoded in JSON as array of steps: positive numbers retain, negative numbers delete
+// and strings insert text, e.g. [5, "text", -3, 10].
+type Operation struct {
+	components []component
+	// BaseLength is the length of the documents the operation applies to
+	BaseLength int
+	// TargetLength is the length of the documents the operation produces
+	TargetLength int
+}
+
+// Retain skips n characters
+func (o *Operation) Retain(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.BaseLength += n
+	o.TargetLength += n
+	if last := len(o.components) - 1; last >= 0 && o.components[last].retain > 0 {
+		o.components[last].retain += n
+		return o
+	}
+	o.components = append(o.components, component{retain: n})
+	return o
+}
+
+// Insert inserts text at the current position
+func (o *Operation) Insert(text string) *Operation {
+	if text == "" {
+		return o
+	}
+	o.TargetLength += utf8.RuneCountInString(text)
+	last := len(o.components) - 1
+	if last >= 0 && o.components[last].insert != "" {
+		o.components[last].insert += text
+		return o
+	}
+	// Inserts are kept in front of deletes, so equal operations always have the same components
+	if last >= 0 && o.components[last].delete > 0 {
+		if last > 0 && o.components[last-1].insert != "" {
+			o.components[last-1].insert += text
+			return o
+		}
+		o.components = append(o.components, o.components[last])
+		o.components[last] = component{insert: text}
+		return o
+	}
+	o.components = append(o.components, component{insert: text})
+	return o
+}
+
+// Delete removes n characters at the current position
+func (o *Operation) Delete(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.BaseLength += n
+	if last := len(o.components) - 1; last >= 0 && o.components[last].delete > 0 {
+		o.components[last].delete += n
+		return o
+	}
+	o.components = append(o.components, component{delete: n})
+	return o
+}
+
+// IsNoop reports whether the operation leaves every document unchanged
+func (o Operation) IsNoop() bool {
+	return len(o.components) == 0 || (len(o.components) == 1 && o.components[0].retain > 0)
+}
+
+// Apply applies the operation to a document
+func (o Operation) Apply(document []rune) ([]rune, error) {
+	if len(document) != o.BaseLength {
+		return nil, fmt.Errorf("%w: operation applies to %d characters, document has %d", ErrLengthMismatch, o.BaseLength, len(document))
+	}
+	result := make([]rune, 0, o.TargetLength)
+	position := 0
+	for _, c := range o.components {
+		switch {
+		case c.retain > 0:
+			result = append(result, document[position:position+c.retain]...)
+			position += c.retain
+		case c.insert != "":
+			result = append(result, []rune(c.insert)...)
+		default:
+			position += c.delete
+		}
+	}
+	return result, nil
+}
+
+// Transform transforms two operations applying to the same document, so that applying a and then b'
+// gives the same document as applying b and then a'. Text inserted by both at the same position
+// ends up with the text of a first.
+func Transform(a Operation, b Operation) (Operation, Operation, error) {
+	if a.BaseLength != b.BaseLength {
+		return Operation{}, Operation{}, fmt.Errorf("%w: operations apply to %d and %d characters", ErrLengthMismatch, a.BaseLength, b.BaseLength)
+	}
+	var aPrime, bPrime Operation
+	as, bs := a.components, b.components
+	var ca, cb *component
+	next := func(components *[]component) *component {
+		if len(*components) == 0 {
+			return nil
+		}
+		c := (*components)[0]
+		*components = (*components)[1:]
+		return &c
+	}
+	ca, cb = next(&as), next(&bs)
+	for ca != nil || cb != nil {
+		if ca != nil && ca.insert != "" {
+			aPrime.Insert(ca.insert)
+			bPrime.Retain(utf8.RuneCountInString(ca.insert))
+			ca = next(&as)
+			continue
+		}
+		if cb != nil && cb.insert != "" {
+			aPrime.Retain(utf8.RuneCountInString(cb.insert))
+			bPrime.Insert(cb.insert)
+			cb = next(&bs)
+			continue
+		}
+		if ca == nil || cb == nil {
+			return Operation{}, Operation{}, fmt.Errorf("%w: operations end at different positions", ErrInvalidOperation)
+		}
+		n := min(length(*ca), length(*cb))
+		switch {
+		case ca.retain > 0 && cb.retain > 0:
+			aPrime.Retain(n)
+			bPrime.Retain(n)
+		case ca.delete > 0 && cb.retain > 0:
+			aPrime.Delete(n)
+		case ca.retain > 0 && cb.delete > 0:
+			bPrime.Delete(n)
+		}
+		// Text deleted by both is gone on either side, nothing is left to transform
+		if ca = consume(ca, n); ca == nil {
+			ca = next(&as)
+		}
+		if cb = consume(cb, n); cb == nil {
+			cb = next(&bs)
+		}
+	}
+	return aPrime, bPrime, nil
+}
+
+func length(c component) int {
+	return c.retain + c.delete
+}
+
+// consume removes n characters from a retain or delete and returns nil once nothing is left
+func consume(c *component, n int) *component {
+	if c.retain > 0 {
+		c.retain -= n
+	} else {
+		c.delete -= n
+	}
+	if length(*c) == 0 {
+		return nil
+	}
+	return c
+}
+
+// TransformIndex moves a position in a document to where it is after the operation was applied.
+// Text inserted at the position is placed in front of it.
+func (o Operation) TransformIndex(index int) int {
+	newIndex := index
+	position := 0
+	for _, c := range o.components {
+		if position > index {
+			break
+		}
+		switch {
+		case c.retain > 0:
+			position += c.retain
+		case c.insert != "":
+			newIndex += utf8.RuneCountInString(c.insert)
+		default:
+			newIndex -= min(c.delete, index-position)
+			position += c.delete
+		}
+	}
+	return newIndex
+}
+
+// MarshalJSON implements json.Marshaler
+func (o Operation) MarshalJSON() ([]byte, error) {
+	steps := make([]any, 0, len(o.components))
+	for _, c := range o.components {
+		switch {
+		case c.retain > 0:
+			steps = append(steps, c.retain)
+		case c.insert != "":
+			steps = append(steps, c.insert)
+		default:
+			steps = append(steps, -c.delete)
+		}
+	}
+	return json.Marshal(steps)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var steps []json.RawMessage
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return err
+	}
+	var op Operation
+	for _, step := range steps {
+		var text string
+		if err := json.Unmarshal(step, &text); err == nil {
+			op.Insert(text)
+			continue
+		}
+		var n int
+		if err := json.Unmarshal(step, &n); err != nil || n == 0 {
+			return fmt.Errorf("%w: unexpected step %s", ErrInvalidOperation, step)
+		}
+		if n > 0 {
+			op.Retain(n)
+		} else {
+			op.Delete(-n)
+		}
+	}
+	*o = op
+	return nil
+}
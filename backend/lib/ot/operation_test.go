@@ -0,0 +1,206 @@
+package ot
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestTransformConverges(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		a        string
+		b        string
+		want     string
+	}{
+		{name: "inserts at different positions", document: "abc", a: `[1, "x", 2]`, b: `[2, "y", 1]`, want: "axbyc"},
+		{name: "inserts at the same position", document: "abc", a: `[1, "x", 2]`, b: `[1, "y", 2]`, want: "axybc"},
+		{name: "inserts into empty document", document: "", a: `["x"]`, b: `["y"]`, want: "xy"},
+		{name: "insert inside deleted text", document: "abcdef", a: `[1, -4, 1]`, b: `[3, "x", 3]`, want: "axf"},
+		{name: "overlapping deletes", document: "abcdef", a: `[1, -3, 2]`, b: `[2, -3, 1]`, want: "af"},
+		{name: "same delete", document: "abc", a: `[1, -1, 1]`, b: `[1, -1, 1]`, want: "ac"},
+		{name: "delete everything against insert", document: "abc", a: `[-3]`, b: `[3, "d"]`, want: "d"},
+		{name: "replace against retain", document: "abc", a: `["x", -3]`, b: `[3]`, want: "x"},
+		{name: "multi-byte runes", document: "héllo wörld", a: `[1, -1, 9]`, b: `[6, "🙂", 5]`, want: "hllo 🙂wörld"},
+		{name: "multi-byte inserts at the same position", document: "ä", a: `[1, "😀"]`, b: `[1, "ß"]`, want: "ä😀ß"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := parseOperation(t, tt.a), parseOperation(t, tt.b)
+			aPrime, bPrime, err := Transform(a, b)
+			if err != nil {
+				t.Fatalf("Transform: %s", err)
+			}
+			document := []rune(tt.document)
+			ab := apply(t, apply(t, document, a), bPrime)
+			ba := apply(t, apply(t, document, b), aPrime)
+			if string(ab) != tt.want {
+				t.Errorf("a then b' = %q, want %q", string(ab), tt.want)
+			}
+			if string(ba) != tt.want {
+				t.Errorf("b then a' = %q, want %q", string(ba), tt.want)
+			}
+		})
+	}
+}
+
+// TestTransformTieBreak checks that text inserted at the same position is ordered by the argument order only
+func TestTransformTieBreak(t *testing.T) {
+	document := []rune("ab")
+	a := parseOperation(t, `[1, "x", 1]`)
+	b := parseOperation(t, `[1, "y", 1]`)
+	for _, tt := range []struct {
+		first  Operation
+		second Operation
+		want   string
+	}{
+		{first: a, second: b, want: "axyb"},
+		{first: b, second: a, want: "ayxb"},
+	} {
+		_, secondPrime, err := Transform(tt.first, tt.second)
+		if err != nil {
+			t.Fatalf("Transform: %s", err)
+		}
+		if got := string(apply(t, apply(t, document, tt.first), secondPrime)); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestTransformLengthMismatch(t *testing.T) {
+	_, _, err := Transform(parseOperation(t, `[3]`), parseOperation(t, `[2, "x"]`))
+	if !errors.Is(err, ErrLengthMismatch) {
+		t.Errorf("Transform error %v, want %v", err, ErrLengthMismatch)
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name      string
+		document  string
+		operation string
+		want      string
+	}{
+		{name: "retain", document: "abc", operation: `[3]`, want: "abc"},
+		{name: "insert", document: "abc", operation: `[1, "xy", 2]`, want: "axybc"},
+		{name: "delete", document: "abc", operation: `[1, -1, 1]`, want: "ac"},
+		{name: "empty", document: "", operation: `[]`, want: ""},
+		{name: "multi-byte runes", document: "a😀b", operation: `[1, -1, "é", 1]`, want: "aéb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(apply(t, []rune(tt.document), parseOperation(t, tt.operation))); got != tt.want {
+				t.Errorf("Apply = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyLengthMismatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		document  string
+		operation string
+	}{
+		{name: "too short", document: "abc", operation: `[2, "x"]`},
+		{name: "too long", document: "abc", operation: `[2, -2]`},
+		// The document has 2 runes but 5 bytes
+		{name: "bytes counted instead of runes", document: "😀b", operation: `[5]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseOperation(t, tt.operation).Apply([]rune(tt.document)); !errors.Is(err, ErrLengthMismatch) {
+				t.Errorf("Apply error %v, want %v", err, ErrLengthMismatch)
+			}
+		})
+	}
+}
+
+func TestTransformIndex(t *testing.T) {
+	operation := parseOperation(t, `[2, "xy", -2, 1]`)
+	for index, want := range []int{0, 1, 4, 4, 4, 5} {
+		if got := operation.TransformIndex(index); got != want {
+			t.Errorf("TransformIndex(%d) = %d, want %d", index, got, want)
+		}
+	}
+}
+
+func TestOperationJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "empty", input: `[]`, want: `[]`},
+		{name: "steps", input: `[2, "x", -1, 3]`, want: `[2,"x",-1,3]`},
+		{name: "merged steps", input: `[1, 1, "a", "b", -1, -1]`, want: `[2,"ab",-2]`},
+		{name: "insert moved in front of delete", input: `[1, -1, "x"]`, want: `[1,"x",-1]`},
+		{name: "multi-byte runes", input: `["😀"]`, want: `["😀"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(parseOperation(t, tt.input))
+			if err != nil {
+				t.Fatalf("Marshal: %s", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOperationJSONLengths(t *testing.T) {
+	operation := parseOperation(t, `[1, "😀é", -2, 3]`)
+	if operation.BaseLength != 6 || operation.TargetLength != 6 {
+		t.Errorf("lengths %d and %d, want 6 and 6", operation.BaseLength, operation.TargetLength)
+	}
+}
+
+func TestOperationJSONInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		// invalid is set for well-formed JSON with steps that are no operation
+		invalid bool
+	}{
+		{name: "syntax error", input: `[1, "x"`},
+		{name: "object", input: `{"retain": 1}`},
+		{name: "string", input: `"text"`},
+		{name: "zero", input: `[0]`, invalid: true},
+		{name: "fraction", input: `[1.5]`, invalid: true},
+		{name: "boolean", input: `[true]`, invalid: true},
+		{name: "nested array", input: `[[1]]`, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var operation Operation
+			err := json.Unmarshal([]byte(tt.input), &operation)
+			if err == nil {
+				t.Fatalf("Unmarshal succeeded, want error")
+			}
+			if tt.invalid && !errors.Is(err, ErrInvalidOperation) {
+				t.Errorf("Unmarshal error %v, want %v", err, ErrInvalidOperation)
+			}
+		})
+	}
+}
+
+func parseOperation(t *testing.T, s string) Operation {
+	t.Helper()
+	var operation Operation
+	if err := json.Unmarshal([]byte(s), &operation); err != nil {
+		t.Fatalf("invalid operation %s: %s", s, err)
+	}
+	return operation
+}
+
+func apply(t *testing.T, document []rune, operation Operation) []rune {
+	t.Helper()
+	result, err := operation.Apply(document)
+	if err != nil {
+		t.Fatalf("Apply: %s", err)
+	}
+	return result
+}
@@ -0,0 +1,265 @@
+// Package search parses the queries of the note search.
+//
+// A query is a list of terms separated by whitespace, all of which have to match. Terms are words, quoted phrases
+// or filters of the form field:value, and are negated with a leading minus:
+//
+//	tag:ops notebook:"Runbooks" updated:>2026-01-01 title:deploy -draft
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Field string
+
+const (
+	// FieldText matches the title or the content of a note
+	FieldText     Field = ""
+	FieldTitle    Field = "title"
+	FieldContent  Field = "content"
+	FieldTag      Field = "tag"
+	FieldNotebook Field = "notebook"
+	FieldUpdated  Field = "updated"
+	FieldCreated  Field = "created"
+)
+
+var fields = map[string]Field{
+	string(FieldTitle):    FieldTitle,
+	string(FieldContent):  FieldContent,
+	string(FieldTag):      FieldTag,
+	string(FieldNotebook): FieldNotebook,
+	string(FieldUpdated):  FieldUpdated,
+	string(FieldCreated):  FieldCreated,
+}
+
+// IsFullText reports whether a field is matched against the full-text index
+func (f Field) IsFullText() bool {
+	return f == FieldText || f == FieldTitle || f == FieldContent
+}
+
+// IsDate reports whether a field is compared to a date
+func (f Field) IsDate() bool {
+	return f == FieldUpdated || f == FieldCreated
+}
+
+// Term is a single condition of a query
+type Term struct {
+	Field   Field
+	Negated bool
+	// Value is the text, tag or notebook title to match
+	Value string
+	// Prefix terms match words starting with Value, written with a trailing *
+	Prefix bool
+	// From and Until bound date terms, From is inclusive and Until exclusive. Either one may be zero.
+	From  time.Time
+	Until time.Time
+	// Position is the byte offset of the term in the query, Token the term as written
+	Position int
+	Token    string
+}
+
+// Query is a parsed search query, a note has to match all of its terms
+type Query struct {
+	Terms []Term
+}
+
+// SyntaxError points at the term of a query that could not be parsed
+type SyntaxError struct {
+	Position int
+	Token    string
+	Reason   string
+}
+
+func (e *SyntaxError) Error() string {
+	if len(e.Token) == 0 {
+		return fmt.Sprintf("%s at position %d", e.Reason, e.Position)
+	}
+	return fmt.Sprintf("%s at position %d: %s", e.Reason, e.Position, e.Token)
+}
+
+// Parse parses a query, see the package documentation for its syntax
+func Parse(query string) (Query, error) {
+	p := parser{query: query}
+	var q Query
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.query) {
+			break
+		}
+		term, err := p.term()
+		if err != nil {
+			return Query{}, err
+		}
+		q.Terms = append(q.Terms, term)
+	}
+	if len(q.Terms) == 0 {
+		return Query{}, &SyntaxError{Position: 0, Reason: "query is empty"}
+	}
+	return q, nil
+}
+
+type parser struct {
+	query string
+	pos   int
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.query) {
+		r, size := utf8.DecodeRuneInString(p.query[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+// term parses [-][field:](word|"phrase")
+func (p *parser) term() (Term, error) {
+	term := Term{Position: p.pos}
+	if p.query[p.pos] == '-' {
+		term.Negated = true
+		p.pos++
+	}
+	valueStart := p.pos
+	value, quoted, err := p.value(true)
+	if err != nil {
+		return Term{}, err
+	}
+	if !quoted && p.pos < len(p.query) && p.query[p.pos] == ':' {
+		name := value
+		field, ok := fields[strings.ToLower(name)]
+		if !ok {
+			return Term{}, p.errorAt(term.Position, fmt.Sprintf("unknown filter %q, quote the term to search for it", name))
+		}
+		term.Field = field
+		p.pos++
+		valueStart = p.pos
+		value, quoted, err = p.value(false)
+		if err != nil {
+			return Term{}, err
+		}
+	}
+	term.Token = p.query[term.Position:p.pos]
+	if len(value) == 0 && !quoted {
+		if term.Field == FieldText {
+			return Term{}, p.errorAt(term.Position, "expected a term after -")
+		}
+		return Term{}, p.errorAt(term.Position, fmt.Sprintf("expected a value after %s:", term.Field))
+	}
+	if p.pos < len(p.query) && !unicode.IsSpace(rune(p.query[p.pos])) {
+		return Term{}, p.errorAt(term.Position, "expected whitespace after quoted value")
+	}
+	switch {
+	case term.Field.IsDate():
+		from, until, err := parseDateRange(value)
+		if err != nil {
+			return Term{}, p.errorAt(valueStart, err.Error())
+		}
+		term.From, term.Until = from, until
+	case term.Field.IsFullText():
+		if !quoted && strings.HasSuffix(value, "*") {
+			term.Prefix = true
+			value = strings.TrimRight(value, "*")
+		}
+		if len(strings.TrimSpace(value)) == 0 {
+			return Term{}, p.errorAt(term.Position, "expected text to search for")
+		}
+		term.Value = value
+	default:
+		if len(strings.TrimSpace(value)) == 0 {
+			return Term{}, p.errorAt(term.Position, fmt.Sprintf("expected a value after %s:", term.Field))
+		}
+		term.Value = value
+	}
+	return term, nil
+}
+
+// value parses a quoted phrase or a word ending at whitespace, or at the first colon if the word may be the name of
+// a field. Quotes within phrases are escaped by doubling them.
+func (p *parser) value(mayBeField bool) (string, bool, error) {
+	if p.pos < len(p.query) && p.query[p.pos] == '"' {
+		start := p.pos
+		p.pos++
+		var b strings.Builder
+		for p.pos < len(p.query) {
+			c := p.query[p.pos]
+			p.pos++
+			if c != '"' {
+				b.WriteByte(c)
+				continue
+			}
+			if p.pos < len(p.query) && p.query[p.pos] == '"' {
+				b.WriteByte('"')
+				p.pos++
+				continue
+			}
+			return b.String(), true, nil
+		}
+		return "", false, p.errorAt(start, "unterminated quote")
+	}
+	start := p.pos
+	for p.pos < len(p.query) {
+		r, size := utf8.DecodeRuneInString(p.query[p.pos:])
+		if unicode.IsSpace(r) || (mayBeField && r == ':') {
+			break
+		}
+		if r == '"' {
+			return "", false, p.errorAt(start, "unexpected quote within term")
+		}
+		p.pos += size
+	}
+	return p.query[start:p.pos], false, nil
+}
+
+// errorAt reports an error for the term starting at start, the token reaches up to the next whitespace
+func (p *parser) errorAt(start int, reason string) *SyntaxError {
+	end := start
+	for end < len(p.query) {
+		r, size := utf8.DecodeRuneInString(p.query[end:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		end += size
+	}
+	return &SyntaxError{Position: start, Token: p.query[start:end], Reason: reason}
+}
+
+const dateLayout = "2006-01-02"
+
+// parseDateRange parses an optional comparison followed by a date or time. A date covers the whole day in UTC,
+// so >2026-01-01 starts with the next day.
+func parseDateRange(value string) (time.Time, time.Time, error) {
+	comparison := ""
+	for _, c := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, c) {
+			comparison = c
+			value = value[len(c):]
+			break
+		}
+	}
+	start, err := time.Parse(dateLayout, value)
+	end := start.AddDate(0, 0, 1)
+	if err != nil {
+		start, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("expected a date like %s or a time like %s", dateLayout, time.RFC3339)
+		}
+		end = start.Add(time.Second)
+	}
+	switch comparison {
+	case ">":
+		return end, time.Time{}, nil
+	case ">=":
+		return start, time.Time{}, nil
+	case "<":
+		return time.Time{}, start, nil
+	case "<=":
+		return time.Time{}, end, nil
+	default:
+		return start, end, nil
+	}
+}
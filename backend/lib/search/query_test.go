@@ -0,0 +1,203 @@
+package search
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []Term
+	}{
+		{
+			name:  "word",
+			query: "deploy",
+			want:  []Term{{Value: "deploy", Position: 0, Token: "deploy"}},
+		},
+		{
+			name:  "several terms",
+			query: "  deploy\tlinux  ",
+			want: []Term{
+				{Value: "deploy", Position: 2, Token: "deploy"},
+				{Value: "linux", Position: 9, Token: "linux"},
+			},
+		},
+		{
+			name:  "phrase",
+			query: `"rolling deploy"`,
+			want:  []Term{{Value: "rolling deploy", Position: 0, Token: `"rolling deploy"`}},
+		},
+		{
+			name:  "phrase with doubled quotes",
+			query: `"say ""hi"" twice"`,
+			want:  []Term{{Value: `say "hi" twice`, Position: 0, Token: `"say ""hi"" twice"`}},
+		},
+		{
+			name:  "phrase of doubled quotes",
+			query: `""""`,
+			want:  []Term{{Value: `"`, Position: 0, Token: `""""`}},
+		},
+		{
+			name:  "quoted colon",
+			query: `"tag:ops"`,
+			want:  []Term{{Value: "tag:ops", Position: 0, Token: `"tag:ops"`}},
+		},
+		{
+			name:  "prefix",
+			query: "depl*",
+			want:  []Term{{Value: "depl", Prefix: true, Position: 0, Token: "depl*"}},
+		},
+		{
+			name:  "prefix of field",
+			query: "title:depl**",
+			want:  []Term{{Field: FieldTitle, Value: "depl", Prefix: true, Position: 0, Token: "title:depl**"}},
+		},
+		{
+			name:  "quoted star",
+			query: `"depl*"`,
+			want:  []Term{{Value: "depl*", Position: 0, Token: `"depl*"`}},
+		},
+		{
+			name:  "star of tag",
+			query: "tag:ops*",
+			want:  []Term{{Field: FieldTag, Value: "ops*", Position: 0, Token: "tag:ops*"}},
+		},
+		{
+			name:  "negated word",
+			query: "-draft",
+			want:  []Term{{Negated: true, Value: "draft", Position: 0, Token: "-draft"}},
+		},
+		{
+			name:  "negated filters",
+			query: `-tag:ops -notebook:"My Runbooks" -content:secret*`,
+			want: []Term{
+				{Field: FieldTag, Negated: true, Value: "ops", Position: 0, Token: "-tag:ops"},
+				{Field: FieldNotebook, Negated: true, Value: "My Runbooks", Position: 9, Token: `-notebook:"My Runbooks"`},
+				{Field: FieldContent, Negated: true, Value: "secret", Prefix: true, Position: 33, Token: "-content:secret*"},
+			},
+		},
+		{
+			name:  "field names ignore case",
+			query: "TAG:Ops",
+			want:  []Term{{Field: FieldTag, Value: "Ops", Position: 0, Token: "TAG:Ops"}},
+		},
+		{
+			name:  "colon within value",
+			query: "tag:a:b",
+			want:  []Term{{Field: FieldTag, Value: "a:b", Position: 0, Token: "tag:a:b"}},
+		},
+		{
+			name:  "positions count bytes",
+			query: "größe tag:ä",
+			want: []Term{
+				{Value: "größe", Position: 0, Token: "größe"},
+				{Field: FieldTag, Value: "ä", Position: 8, Token: "tag:ä"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse: %s", err)
+			}
+			if !slices.EqualFunc(q.Terms, tt.want, equalTerms) {
+				t.Errorf("terms %+v, want %+v", q.Terms, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDates(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	nextDay := day.AddDate(0, 0, 1)
+	moment := time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)
+	nextSecond := moment.Add(time.Second)
+	tests := []struct {
+		name  string
+		query string
+		from  time.Time
+		until time.Time
+	}{
+		{name: "date", query: "updated:2026-01-01", from: day, until: nextDay},
+		{name: "equal date", query: "updated:=2026-01-01", from: day, until: nextDay},
+		{name: "after date", query: "updated:>2026-01-01", from: nextDay},
+		{name: "from date", query: "updated:>=2026-01-01", from: day},
+		{name: "before date", query: "updated:<2026-01-01", until: day},
+		{name: "until date", query: "updated:<=2026-01-01", until: nextDay},
+		{name: "time", query: "created:2026-01-01T10:30:00Z", from: moment, until: nextSecond},
+		{name: "equal time", query: "created:=2026-01-01T10:30:00Z", from: moment, until: nextSecond},
+		{name: "after time", query: "created:>2026-01-01T10:30:00Z", from: nextSecond},
+		{name: "from time", query: "created:>=2026-01-01T10:30:00Z", from: moment},
+		{name: "before time", query: "created:<2026-01-01T10:30:00Z", until: moment},
+		{name: "until time", query: "created:<=2026-01-01T10:30:00Z", until: nextSecond},
+		{name: "time with offset", query: "created:>=2026-01-01T12:30:00+02:00", from: moment},
+		{name: "quoted date", query: `updated:">2026-01-01"`, from: nextDay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse: %s", err)
+			}
+			if len(q.Terms) != 1 {
+				t.Fatalf("%d terms, want 1", len(q.Terms))
+			}
+			term := q.Terms[0]
+			if !term.From.Equal(tt.from) || !term.Until.Equal(tt.until) {
+				t.Errorf("range %s to %s, want %s to %s", term.From, term.Until, tt.from, tt.until)
+			}
+			if !term.Field.IsDate() || term.Value != "" {
+				t.Errorf("term %+v is no date term", term)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		position int
+		token    string
+	}{
+		{name: "empty", query: "", position: 0, token: ""},
+		{name: "only whitespace", query: " \t ", position: 0, token: ""},
+		{name: "unknown field", query: "deploy owner:me", position: 7, token: "owner:me"},
+		{name: "negated unknown field", query: "-owner:me", position: 0, token: "-owner:me"},
+		{name: "unterminated quote", query: `deploy "rolling deploy`, position: 7, token: `"rolling`},
+		{name: "unterminated quote after field", query: `tag:"ops`, position: 4, token: `"ops`},
+		{name: "quote within term", query: `dep"loy`, position: 0, token: `dep"loy`},
+		{name: "text after quote", query: `"deploy"s`, position: 0, token: `"deploy"s`},
+		{name: "lone minus", query: "deploy -", position: 7, token: "-"},
+		{name: "missing value", query: "tag: ops", position: 0, token: "tag:"},
+		{name: "blank quoted value", query: `notebook:"  "`, position: 0, token: `notebook:"`},
+		{name: "only star", query: "*", position: 0, token: "*"},
+		{name: "empty phrase", query: `""`, position: 0, token: `""`},
+		{name: "invalid date", query: "updated:>2026-13-01", position: 8, token: ">2026-13-01"},
+		{name: "invalid date after negation", query: "x -created:yesterday", position: 11, token: "yesterday"},
+		{name: "unknown comparison", query: "updated:!2026-01-01", position: 8, token: "!2026-01-01"},
+		{name: "position counts bytes", query: "größe foo:bar", position: 8, token: "foo:bar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse error %v, want a syntax error", err)
+			}
+			if syntaxErr.Position != tt.position || syntaxErr.Token != tt.token {
+				t.Errorf("error at %d: %q, want %d: %q", syntaxErr.Position, syntaxErr.Token, tt.position, tt.token)
+			}
+		})
+	}
+}
+
+func equalTerms(a Term, b Term) bool {
+	return a.Field == b.Field && a.Negated == b.Negated && a.Value == b.Value && a.Prefix == b.Prefix &&
+		a.From.Equal(b.From) && a.Until.Equal(b.Until) && a.Position == b.Position && a.Token == b.Token
+}
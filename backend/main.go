@@ -5,7 +5,6 @@ import (
 	"flag"
 	"log"
 	"os"
-	"os/exec"
 	"os/signal"
 	"syscall"
 
@@ -15,6 +14,8 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+var reindex = flag.Bool("reindex", false, "Rebuild the search index, links and note sizes from the stored notes and exit")
+
 //	@title		Bongo Notes backend
 //	@version	1.0
 
@@ -24,15 +25,6 @@ import (
 // @in							header
 // @name						Authorization
 func main() {
-	missingBinary, ok := validateEnvironment()
-	if !ok {
-		log.Fatalf("%s not found", missingBinary)
-	}
-
-	appContext, cancel := context.WithCancel(context.Background())
-	signalCh := make(chan os.Signal, 1)
-	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
-
 	config, err := getConfig()
 	if err != nil {
 		log.Fatal(err)
@@ -42,6 +34,17 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if *reindex {
+		if err := api.Reindex(config); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	appContext, cancel := context.WithCancel(context.Background())
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
+
 	errCh := make(chan struct{})
 	doneCh := make(chan struct{})
 	go api.InitApi(appContext, errCh, doneCh, config)
@@ -73,21 +76,3 @@ func getConfig() (config.Config, error) {
 	return config, nil
 
 }
-
-func validateEnvironment() (string, bool) {
-	binaries := []string{"diff", "patch"}
-	for _, b := range binaries {
-		if !isCommandAvailable(b) {
-			return b, false
-		}
-	}
-	return "", true
-}
-
-func isCommandAvailable(cmd string) bool {
-	res, err := exec.LookPath(cmd)
-	if err != nil {
-		log.Println(err)
-	}
-	return err == nil || res != ""
-}
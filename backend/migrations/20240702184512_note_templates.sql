@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE note_templates(
+    id text not null unique,
+    owner_id text not null,
+    notebook_id text,
+    name text not null,
+    title text not null,
+    content text not null,
+    created_at timestamp not null default (strftime('%s','now')),
+    updated_at timestamp not null default (strftime('%s','now')),
+
+    FOREIGN KEY(owner_id) REFERENCES users(id),
+    FOREIGN KEY(notebook_id) REFERENCES notebooks(id)
+);
+CREATE INDEX idx_note_templates_owner_id on note_templates(owner_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_note_templates_owner_id;
+DROP TABLE note_templates;
+-- +goose StatementEnd
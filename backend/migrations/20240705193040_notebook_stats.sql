@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE notes ADD COLUMN size integer not null default 0;
+CREATE INDEX idx_notes_notebook_id on notes(notebook_id);
+CREATE INDEX idx_note_diffs_note_id on note_diffs(note_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_note_diffs_note_id;
+DROP INDEX idx_notes_notebook_id;
+ALTER TABLE notes DROP COLUMN size;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE notebooks ADD COLUMN pinned boolean not null default 0;
+ALTER TABLE notebooks ADD COLUMN favourite boolean not null default 0;
+ALTER TABLE notebooks ADD COLUMN sort_order integer not null default 0;
+ALTER TABLE notebooks ADD COLUMN color text not null default '';
+ALTER TABLE notebooks ADD COLUMN icon text not null default '';
+ALTER TABLE notebooks ADD COLUMN emoji text not null default '';
+UPDATE notebooks SET sort_order = rowid;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE notebooks DROP COLUMN emoji;
+ALTER TABLE notebooks DROP COLUMN icon;
+ALTER TABLE notebooks DROP COLUMN color;
+ALTER TABLE notebooks DROP COLUMN sort_order;
+ALTER TABLE notebooks DROP COLUMN favourite;
+ALTER TABLE notebooks DROP COLUMN pinned;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Diffs are ordered per note by their version, created_at is only precise to the second
+DROP INDEX idx_diffs_created_at;
+ALTER TABLE note_diffs ADD COLUMN version integer not null default 0;
+UPDATE note_diffs SET version = (
+    SELECT COUNT(*) FROM note_diffs AS d WHERE d.note_id = note_diffs.note_id AND d.rowid <= note_diffs.rowid
+);
+-- Every note gets a version 0 for its content at creation time
+INSERT INTO note_diffs(id, note_id, created_at, version)
+SELECT
+    lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' || substr(lower(hex(randomblob(2))), 2) || '-' ||
+    substr('89ab', 1 + (abs(random()) % 4), 1) || substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6))),
+    id, created_at, 0
+FROM notes;
+CREATE UNIQUE INDEX idx_note_diffs_note_version on note_diffs(note_id, version);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_note_diffs_note_version;
+DELETE FROM note_diffs WHERE version = 0;
+ALTER TABLE note_diffs DROP COLUMN version;
+CREATE UNIQUE INDEX idx_diffs_created_at on note_diffs(created_at);
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE note_diffs ADD COLUMN author_id text REFERENCES users(id);
+ALTER TABLE note_diffs ADD COLUMN size integer not null default 0;
+ALTER TABLE note_diffs ADD COLUMN line_count integer not null default 0;
+ALTER TABLE note_diffs ADD COLUMN lines_added integer not null default 0;
+ALTER TABLE note_diffs ADD COLUMN lines_removed integer not null default 0;
+ALTER TABLE note_diffs ADD COLUMN diff_size integer not null default 0;
+-- Size is only known for the most recent version of existing notes
+UPDATE note_diffs SET size = (SELECT notes.size FROM notes WHERE notes.id = note_diffs.note_id)
+WHERE version = (SELECT MAX(d.version) FROM note_diffs AS d WHERE d.note_id = note_diffs.note_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE note_diffs DROP COLUMN diff_size;
+ALTER TABLE note_diffs DROP COLUMN lines_removed;
+ALTER TABLE note_diffs DROP COLUMN lines_added;
+ALTER TABLE note_diffs DROP COLUMN line_count;
+ALTER TABLE note_diffs DROP COLUMN size;
+ALTER TABLE note_diffs DROP COLUMN author_id;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE note_snapshots(
+    version_id text not null unique,
+    note_id text not null,
+    version integer not null,
+    size integer not null default 0,
+    created_at timestamp not null default (strftime('%s','now')),
+
+    FOREIGN KEY(note_id) REFERENCES notes(id),
+    FOREIGN KEY(version_id) REFERENCES note_diffs(id)
+);
+CREATE UNIQUE INDEX idx_note_snapshots_note_version on note_snapshots(note_id, version);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_note_snapshots_note_version;
+DROP TABLE note_snapshots;
+-- +goose StatementEnd
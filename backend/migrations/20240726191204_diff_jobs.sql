@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE diff_jobs(
+    id text not null unique,
+    note_id text not null,
+    author_id text,
+    state text not null default 'queued',
+    attempts integer not null default 0,
+    error text not null default '',
+    version_id text,
+    next_attempt_at timestamp not null default (strftime('%s','now')),
+    created_at timestamp not null default (strftime('%s','now')),
+    updated_at timestamp not null default (strftime('%s','now')),
+
+    FOREIGN KEY(note_id) REFERENCES notes(id),
+    FOREIGN KEY(author_id) REFERENCES users(id)
+);
+CREATE INDEX idx_diff_jobs_state on diff_jobs(state);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_diff_jobs_state;
+DROP TABLE diff_jobs;
+-- +goose StatementEnd
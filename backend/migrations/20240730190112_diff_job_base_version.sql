@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE diff_jobs ADD COLUMN base_version_id text;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE diff_jobs DROP COLUMN base_version_id;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE VIRTUAL TABLE note_search USING fts5(
+    note_id UNINDEXED,
+    title,
+    content,
+    tokenize = 'porter unicode61 remove_diacritics 2'
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- Content is stored on disk and indexed by running the server with -reindex
+INSERT INTO note_search(note_id, title, content) SELECT id, title, '' FROM notes;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE note_search;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE tags(
+    id text not null unique,
+    owner_id text not null,
+    name text not null,
+    created_at timestamp not null default (strftime('%s','now')),
+
+    FOREIGN KEY(owner_id) REFERENCES users(id)
+);
+CREATE UNIQUE INDEX idx_tags_owner_id_name on tags(owner_id, name COLLATE NOCASE);
+CREATE TABLE note_tags(
+    note_id text not null,
+    tag_id text not null,
+
+    PRIMARY KEY(note_id, tag_id),
+    FOREIGN KEY(note_id) REFERENCES notes(id),
+    FOREIGN KEY(tag_id) REFERENCES tags(id)
+);
+CREATE INDEX idx_note_tags_tag_id on note_tags(tag_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_note_tags_tag_id;
+DROP TABLE note_tags;
+DROP INDEX idx_tags_owner_id_name;
+DROP TABLE tags;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE note_links(
+    source_note_id text not null,
+    target text not null, --Title or id of the linked note, resolved when links are queried
+
+    FOREIGN KEY(source_note_id) REFERENCES notes(id)
+);
+CREATE UNIQUE INDEX idx_note_links_source_note_id_target on note_links(source_note_id, target COLLATE NOCASE);
+CREATE INDEX idx_note_links_target on note_links(target COLLATE NOCASE);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_note_links_target;
+DROP INDEX idx_note_links_source_note_id_target;
+DROP TABLE note_links;
+-- +goose StatementEnd